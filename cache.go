@@ -4,9 +4,12 @@
 package polyfill
 
 import (
-	"container/list"
+	"context"
 	"errors"
+	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +18,9 @@ var (
 	ErrExists = errors.New("cache: key already exists")
 	// ErrNotFound is returned when Update/Delete operate on a missing key.
 	ErrNotFound = errors.New("cache: key not found")
+	// ErrSupplierPanic is returned to callers waiting on a GetOrSet load
+	// whose supplier panicked.
+	ErrSupplierPanic = errors.New("cache: supplier panicked")
 )
 
 // EvictReason explains why an entry was removed.
@@ -33,43 +39,289 @@ type Config struct {
 	// If DefaultTTL<=0, items created with ttl==0 won't expire.
 	DefaultTTL time.Duration
 
-	// MaxItems enables LRU if >0. When size exceeds the limit,
-	// least-recently-used items are evicted.
+	// MaxItems enables eviction if >0. When size exceeds the limit, the
+	// Policy (LRU by default) picks which items to evict.
+	// With Shards>1 the limit is split evenly and enforced per shard.
 	MaxItems int
 
-	// OnEvict is called whenever an entry is removed (expired/capacity/clear/manual).
+	// MaxCost enables eviction by total entry cost if >0. Entries are
+	// evicted (reason EvictCapacity) until the summed cost fits. Costs come
+	// from SetWithCost, CacheOptions.Cost, or default to 1. An entry
+	// costing more than MaxCost is evicted right away.
+	// With Shards>1 the budget is split evenly and enforced per shard; an
+	// entry larger than its shard's share is still kept, alone in its
+	// shard, so the total may then exceed MaxCost by up to that entry.
+	MaxCost int64
+
+	// Policy selects the built-in eviction policy used when MaxItems>0 or
+	// MaxCost>0 ("" = PolicyLRU). CacheOptions.NewPolicy overrides it.
+	Policy PolicyKind
+
+	// OnEvict is called whenever an entry is removed (expired/capacity/clear/manual),
+	// after the cache lock is released. CacheOptions.EvictListener is the
+	// typed equivalent.
 	OnEvict func(key any, value any, reason EvictReason)
+
+	// AsyncEvents delivers eviction callbacks and Subscribe events from a
+	// background goroutine, in order, instead of on the calling goroutine.
+	AsyncEvents bool
+
+	// CleanupInterval starts a background janitor that calls Sweep at this
+	// interval when >0. Call Close to stop it.
+	CleanupInterval time.Duration
+
+	// ExpirationResolution tracks deadlines in a timing wheel advanced at
+	// this interval when >0, so expired entries are removed (and OnEvict
+	// fires with EvictExpired) within about one interval of their deadline
+	// instead of waiting for an access or Sweep. Call Close to stop it.
+	ExpirationResolution time.Duration
+
+	// TTLJitter shortens each positive TTL by a random fraction of up to
+	// this much (0..1), so entries written together don't expire together.
+	TTLJitter float64
+
+	// RefreshAfter makes GetOrSet reload entries older than this in the
+	// background: the current value is returned immediately and a single
+	// asynchronous supplier call replaces it (0 = disabled).
+	RefreshAfter time.Duration
+
+	// ExpireAfterAccess makes entries expire once they go this long without
+	// being read or written (0 = disabled). Reads slide the expiration
+	// forward; a write TTL, if any, still caps it absolutely.
+	ExpireAfterAccess time.Duration
+
+	// StaleWhileRevalidate keeps expired entries for this long; GetOrSet
+	// serves them while one background reload runs (0 = disabled).
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError keeps expired entries for this long; if GetOrSet's reload
+	// fails, the stale value is returned instead of the error (0 = disabled).
+	StaleIfError time.Duration
+
+	// Shards partitions keys across this many independently locked segments
+	// to reduce contention (<=1 = a single segment). Each shard keeps its
+	// own eviction policy, so recency is tracked per shard, and gets an
+	// equal share of MaxItems/MaxCost (so there are at most that many).
+	Shards int
+
+	// SnapshotCodec encodes Snapshot/Restore streams (nil = GobSnapshot).
+	SnapshotCodec SnapshotCodec
+
+	// SnapshotPath is written atomically every SnapshotInterval (when >0)
+	// and once more on Close. Load it at startup with RestoreFile.
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+
+	// OnSnapshotError reports failures of the background snapshots.
+	OnSnapshotError func(error)
+
+	// Clock supplies the time for expiry, refresh and background work
+	// (nil = the system clock). Use fakeclock.Clock in tests.
+	Clock Clock
+}
+
+// CacheOptions extends Config with settings that depend on the key and
+// value types. Use it with NewCacheWith.
+type CacheOptions[K comparable, V any] struct {
+	Config
+
+	// Hasher maps keys to shards when Shards>1. The default handles strings
+	// and integers directly and walks other key types with reflect, so
+	// supplying a Hasher is worthwhile for hot struct or array keys.
+	// PolicyTinyLFU also uses it to feed its frequency sketch.
+	Hasher func(K) uint64
+
+	// NewPolicy builds a custom eviction policy for a shard of the given
+	// capacity (0 when only MaxCost limits it), taking precedence over
+	// Config.Policy.
+	NewPolicy func(capacity int) EvictionPolicy[K]
+
+	// Cost computes an entry's weight for MaxCost when it is stored
+	// without an explicit cost. nil gives every entry a cost of 1.
+	Cost func(K, V) int64
+
+	// Expiry derives TTLs from the entries themselves. It takes the place
+	// of DefaultTTL: writes with ttl==0 (including Update) ask it, and
+	// every read may adjust the expiration.
+	Expiry Expiry[K, V]
+
+	// EvictListener is a typed OnEvict. It runs after the cache lock is
+	// released, so it may call back into the cache.
+	EvictListener func(key K, value V, reason EvictReason)
 }
 
 // Cache is a generic, thread-safe in-memory cache with optional LRU.
 type Cache[K comparable, V any] struct {
+	shards    []*cacheShard[K, V]
+	hash      func(K) uint64
+	newPolicy func(capacity int) EvictionPolicy[K]
+	cost      func(K, V) int64
+
+	cfg     Config
+	clock   Clock
+	random  func() float64 // TTLJitter source in [0,1)
+	stats   cacheCounters
+	onEvict func(K, V, EvictReason)
+	expiry  Expiry[K, V]
+	events  eventHub[K, V]
+	version atomic.Uint64 // last entry version handed out
+
+	// in-flight GetOrSet loads, guarded by callMu rather than a shard lock
+	// so a slow supplier never blocks other cache operations
+	callMu sync.Mutex
+	calls  map[K]*call[V]
+
+	// background janitor and snapshotter, stopped by Close
+	quit      chan struct{}
+	bg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// cacheShard is an independently locked partition of the cache.
+type cacheShard[K comparable, V any] struct {
+	c     *Cache[K, V]
 	mu    sync.RWMutex
-	items map[K]*entry[V]
+	items map[K]*entry[K, V]
+
+	// events recorded under mu, delivered by unlock
+	pending []CacheEvent[K, V]
 
-	// LRU bookkeeping (only used if MaxItems>0)
-	ll    *list.List          // front = most recently used
-	index map[K]*list.Element // key -> node
+	// tag -> keys carrying it, for InvalidateTag
+	tags map[string]map[K]struct{}
+
+	// in-flight loads of this shard's keys; writing or deleting a key drops
+	// its call so the load's outdated result isn't stored over the change
+	loads map[K]*call[V]
+
+	// deadlines (only used if ExpirationResolution>0)
+	wheel *timerWheel[K, V]
+
+	// eviction bookkeeping (only used if MaxItems>0 or MaxCost>0)
+	policy   EvictionPolicy[K]
+	maxItems int
+	maxCost  int64
 
-	cfg  Config
 	size int
-	now  func() time.Time // for testing: injectable clock
+	cost int64 // sum of entry costs
 }
 
-type entry[V any] struct {
+type entry[K comparable, V any] struct {
+	key       K
 	val       V
 	expiresAt time.Time // zero => no expiration
+	writtenAt time.Time // when the value was stored, for RefreshAfter
+	cost      int64
+	version   uint64 // from Cache.version, bumped on every value change
+
+	// sliding expiration: expiresAt = min(last access + idle, maxExpiresAt)
+	idle         time.Duration
+	maxExpiresAt time.Time // write-TTL cap, zero => none
+
+	tags  []string
+	timer timerNode[K, V] // ExpirationResolution wheel link
+}
+
+// lookupState classifies an entry found by GetOrSet.
+type lookupState int
+
+const (
+	lookupMiss         lookupState = iota
+	lookupHit                      // live and fresh
+	lookupRefresh                  // live but older than RefreshAfter
+	lookupStale                    // expired, within StaleWhileRevalidate
+	lookupStaleIfError             // expired, usable only if a reload fails
+)
+
+// call is a single in-flight supplier invocation shared by all callers
+// asking for the same missing key.
+type call[V any] struct {
+	done  chan struct{}
+	val   V
+	err   error
+	panic any // recovered from supplier, re-raised by an inline caller
 }
 
-// NewCache creates a new Cache. If cfg.MaxItems>0, LRU is enabled.
+// NewCache creates a new Cache. If cfg.MaxItems>0, eviction (LRU unless
+// cfg.Policy says otherwise) is enabled.
 func NewCache[K comparable, V any](cfg Config) *Cache[K, V] {
+	return NewCacheWith(CacheOptions[K, V]{Config: cfg})
+}
+
+// NewCacheWith creates a new Cache from options that may carry typed
+// settings such as a custom Hasher.
+//
+// Example:
+//
+//	c := NewCacheWith(CacheOptions[string, int]{Config: Config{Shards: 16}})
+func NewCacheWith[K comparable, V any](opts CacheOptions[K, V]) *Cache[K, V] {
+	cfg := opts.Config
+	n := cfg.Shards
+	// every shard needs a nonzero share of the limits
+	if cfg.MaxItems > 0 {
+		n = min(n, cfg.MaxItems)
+	}
+	if cfg.MaxCost > 0 {
+		n = int(min(int64(n), cfg.MaxCost))
+	}
+	if n < 1 {
+		n = 1
+	}
 	c := &Cache[K, V]{
-		items: make(map[K]*entry[V]),
-		index: make(map[K]*list.Element),
-		cfg:   cfg,
-		now:   time.Now,
+		shards:  make([]*cacheShard[K, V], n),
+		hash:    opts.Hasher,
+		cost:    opts.Cost,
+		calls:   make(map[K]*call[V]),
+		cfg:     cfg,
+		clock:   clockOr(cfg.Clock),
+		onEvict: opts.EvictListener,
+		expiry:  opts.Expiry,
 	}
-	if cfg.MaxItems > 0 {
-		c.ll = list.New()
+	c.random = rand.Float64
+	c.events.idle.L = &c.events.qMu
+	if c.hash == nil && (n > 1 || cfg.Policy == PolicyTinyLFU) {
+		c.hash = defaultHasher[K]()
+	}
+	if cfg.MaxItems > 0 || cfg.MaxCost > 0 {
+		c.newPolicy = func(capacity int) EvictionPolicy[K] {
+			if opts.NewPolicy != nil {
+				return opts.NewPolicy(capacity)
+			}
+			return newPolicy(cfg.Policy, capacity, c.hash)
+		}
+	}
+	for i := range c.shards {
+		sh := &cacheShard[K, V]{
+			c:     c,
+			items: make(map[K]*entry[K, V]),
+		}
+		if cfg.ExpirationResolution > 0 {
+			sh.wheel = newTimerWheel[K, V](cfg.ExpirationResolution, c.clock.Now())
+		}
+		if c.newPolicy != nil {
+			// split the limits exactly, the first shards taking the remainder
+			sh.maxItems = cfg.MaxItems / n
+			if i < cfg.MaxItems%n {
+				sh.maxItems++
+			}
+			sh.maxCost = cfg.MaxCost / int64(n)
+			if int64(i) < cfg.MaxCost%int64(n) {
+				sh.maxCost++
+			}
+			sh.policy = c.newPolicy(sh.maxItems)
+		}
+		c.shards[i] = sh
+	}
+	if cfg.CleanupInterval > 0 || cfg.ExpirationResolution > 0 || (cfg.SnapshotPath != "" && cfg.SnapshotInterval > 0) {
+		c.quit = make(chan struct{})
+	}
+	if cfg.ExpirationResolution > 0 {
+		c.every(cfg.ExpirationResolution, c.expireDue)
+	}
+	if cfg.CleanupInterval > 0 {
+		c.every(cfg.CleanupInterval, func() { c.Sweep() })
+	}
+	if cfg.SnapshotPath != "" && cfg.SnapshotInterval > 0 {
+		c.every(cfg.SnapshotInterval, c.autoSnapshot)
 	}
 	return c
 }
@@ -81,342 +333,787 @@ func NewCache[K comparable, V any](cfg Config) *Cache[K, V] {
 //	ttl == 0 => uses DefaultTTL; if DefaultTTL<=0 => no expiration
 //	ttl > 0  => expires at now + ttl
 func (c *Cache[K, V]) Set(key K, val V, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.SetWithCost(key, val, -1, ttl)
+}
 
-	en := &entry[V]{val: val}
-	switch {
-	case ttl < 0:
-		// immortal
-	case ttl == 0 && c.cfg.DefaultTTL > 0:
-		en.expiresAt = c.now().Add(c.cfg.DefaultTTL)
-	case ttl > 0:
-		en.expiresAt = c.now().Add(ttl)
-	}
-	if _, ok := c.items[key]; !ok {
-		c.size++
-	}
-	c.items[key] = en
-	c.touchLRULocked(key)
-	c.enforceCapacityLocked()
+// SetWithCost stores/replaces a value with an explicit cost counted against
+// MaxCost. cost<0 falls back to CacheOptions.Cost (or 1). An entry costing
+// more than the whole budget is evicted immediately instead of flushing
+// everything else. ttl semantics like Set.
+func (c *Cache[K, V]) SetWithCost(key K, val V, cost int64, ttl time.Duration) {
+	c.store(key, c.newEntry(key, val, cost, ttl, c.cfg.ExpireAfterAccess), ttl == 0 && c.expiry != nil)
+}
+
+// SetUntil stores/replaces a value that expires at deadline (zero = never),
+// e.g. a token's own expiry time.
+func (c *Cache[K, V]) SetUntil(key K, val V, deadline time.Time) {
+	en := c.newEntry(key, val, -1, -1, c.cfg.ExpireAfterAccess)
+	en.expiresAt = deadline
+	en.maxExpiresAt = deadline
+	en.slide(en.writtenAt)
+	c.store(key, en, false)
+}
+
+// SetSliding stores a value that expires after idle without reads,
+// overriding Config.ExpireAfterAccess for this entry. ttl caps the total
+// lifetime with Set semantics (ttl<0 = no cap).
+//
+// Example:
+//
+//	sessions.SetSliding(id, sess, 30*time.Minute, 12*time.Hour)
+func (c *Cache[K, V]) SetSliding(key K, val V, idle, ttl time.Duration) {
+	c.store(key, c.newEntry(key, val, -1, ttl, idle), false)
+}
+
+// store inserts or replaces key with en and enforces capacity, letting
+// Expiry pick the TTL if useExpiry.
+func (c *Cache[K, V]) store(key K, en *entry[K, V], useExpiry bool) {
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	sh.storeLocked(key, en, useExpiry)
 }
 
 // Add inserts only if key does not exist (or existed but is expired).
 func (c *Cache[K, V]) Add(key K, val V, ttl time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if _, ok := c.items[key]; ok {
-		if c.expiredLocked(key) {
-			// treat as new insert
-		} else {
-			return ErrExists
-		}
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	if _, ok := sh.addLocked(key, val, ttl); !ok {
+		return ErrExists
 	}
-	en := &entry[V]{val: val}
-	switch {
-	case ttl < 0:
-	case ttl == 0 && c.cfg.DefaultTTL > 0:
-		en.expiresAt = c.now().Add(c.cfg.DefaultTTL)
-	case ttl > 0:
-		en.expiresAt = c.now().Add(ttl)
-	}
-	c.items[key] = en
-	c.size++
-	c.touchLRULocked(key)
-	c.enforceCapacityLocked()
 	return nil
 }
 
 // Update applies a function to the existing value.
 func (c *Cache[K, V]) Update(key K, update func(*V) error) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
 
-	if c.expiredLocked(key) || c.items[key] == nil {
+	if sh.expiredLocked(key) || sh.items[key] == nil {
 		return ErrNotFound
 	}
-	if err := update(&c.items[key].val); err != nil {
+	en := sh.items[key]
+	if err := update(&en.val); err != nil {
 		return err
 	}
-	c.touchLRULocked(key)
+	sh.changedLocked(key, en)
 	return nil
 }
 
 // Get returns (value, true) if the key exists and is not expired. It updates LRU.
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	var zero V
-	if c.expiredLocked(key) {
-		return zero, false
-	}
-	en, ok := c.items[key]
-	if !ok {
-		return zero, false
-	}
-	c.touchLRULocked(key)
-	return en.val, true
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	return sh.getLocked(key)
 }
 
 // GetOrSet returns the current value, or if missing/expired, uses supplier to create:
 // supplier returns (value, ttl, error) with same ttl semantics as Set.
+// Concurrent callers for the same key share a single supplier call, and the
+// supplier runs without holding the cache lock so other keys are unaffected.
+// Errors are returned to every waiting caller and nothing is stored.
+//
+// With RefreshAfter or StaleWhileRevalidate the supplier may instead run in
+// the background while the current value is returned, and with StaleIfError
+// a failed reload falls back to the expired value.
 func (c *Cache[K, V]) GetOrSet(key K, supplier func() (V, time.Duration, error)) (V, error) {
+	return c.GetOrSetCtx(context.Background(), key, supplier)
+}
+
+// GetOrSetCtx is like GetOrSet but stops waiting when ctx is done, returning
+// ctx.Err(). The load itself keeps running and its result is still stored,
+// so callers that remain (or arrive later) can use it.
+func (c *Cache[K, V]) GetOrSetCtx(ctx context.Context, key K, supplier func() (V, time.Duration, error)) (V, error) {
 	// optimistic read path
-	if v, ok := c.peek(key); ok {
+	v, state := c.lookup(key)
+	switch state {
+	case lookupHit:
+		c.stats.hits.Add(1)
+		return v, nil
+	case lookupRefresh, lookupStale:
+		c.stats.hits.Add(1)
+		c.refresh(key, supplier)
 		return v, nil
 	}
-	// serialize production per key
-	c.mu.Lock()
-	if !c.expiredLocked(key) {
-		if en, ok := c.items[key]; ok {
-			v := en.val
-			c.touchLRULocked(key)
-			c.mu.Unlock()
-			return v, nil
-		}
-	}
-	val, ttl, err := supplier()
-	if err != nil {
-		c.mu.Unlock()
-		var zero V
-		return zero, err
-	}
-	en := &entry[V]{val: val}
-	switch {
-	case ttl < 0:
-	case ttl == 0 && c.cfg.DefaultTTL > 0:
-		en.expiresAt = c.now().Add(c.cfg.DefaultTTL)
-	case ttl > 0:
-		en.expiresAt = c.now().Add(ttl)
+	c.stats.misses.Add(1)
+
+	val, err := c.loadShared(ctx, key, supplier)
+	if err != nil && state == lookupStaleIfError && ctx.Err() == nil {
+		return v, nil
 	}
-	c.items[key] = en
-	c.size++
-	c.touchLRULocked(key)
-	c.enforceCapacityLocked()
-	c.mu.Unlock()
-	return val, nil
+	return val, err
 }
 
 // Has reports whether key exists and is not expired.
 func (c *Cache[K, V]) Has(key K) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.hasLocked(key)
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.hasLocked(key)
 }
 
 // TTL returns remaining time until expiration for key. If item does not expire, ok=false.
 func (c *Cache[K, V]) TTL(key K) (remaining time.Duration, ok bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	en, ok := c.items[key]
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	en, ok := sh.items[key]
 	if !ok || c.isExpired(en) {
 		return 0, false
 	}
 	if en.expiresAt.IsZero() {
 		return 0, false
 	}
-	return time.Until(en.expiresAt), true
+	return en.expiresAt.Sub(c.clock.Now()), true
 }
 
-// Touch refreshes the expiration. ttl semantics like Set.
-// Returns false if key missing/expired.
+// Touch refreshes the expiration. ttl semantics like Set; for sliding
+// entries it replaces the lifetime cap. Returns false if key missing/expired.
 func (c *Cache[K, V]) Touch(key K, ttl time.Duration) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	en, ok := c.items[key]
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	en, ok := sh.items[key]
 	if !ok || c.isExpired(en) {
 		return false
 	}
-	switch {
-	case ttl < 0:
-		en.expiresAt = time.Time{}
-	case ttl == 0 && c.cfg.DefaultTTL > 0:
-		en.expiresAt = c.now().Add(c.cfg.DefaultTTL)
-	case ttl > 0:
-		en.expiresAt = c.now().Add(ttl)
+	if ttl == 0 && c.cfg.DefaultTTL <= 0 {
+		// keep the current expiration
+	} else {
+		c.retime(en, ttl, c.clock.Now())
 	}
-	c.touchLRULocked(key)
+	sh.accessLocked(key)
 	return true
 }
 
 // Delete removes a key. Returns true if it existed.
 func (c *Cache[K, V]) Delete(key K) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	en, ok := c.items[key]
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	sh.supersedeLocked(key)
+	en, ok := sh.items[key]
 	if !ok {
 		return false
 	}
-	delete(c.items, key)
-	c.size--
-	c.removeLRUKeyLocked(key)
-	if c.cfg.OnEvict != nil {
-		c.cfg.OnEvict(any(key), any(en.val), EvictManual)
-	}
+	sh.removeKeyLocked(key, en, EvictManual)
 	return true
 }
 
 // Len returns the number of live (non-expired) entries.
 func (c *Cache[K, V]) Len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.sizeAliveLocked()
+	n := 0
+	for _, sh := range c.shards {
+		sh.mu.RLock()
+		n += sh.sizeAliveLocked()
+		sh.mu.RUnlock()
+	}
+	return n
 }
 
 // Keys returns a copy of live keys.
 func (c *Cache[K, V]) Keys() []K {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	keys := make([]K, 0, c.size)
-	for k, en := range c.items {
-		if !c.isExpired(en) {
-			keys = append(keys, k)
+	var keys []K
+	for _, sh := range c.shards {
+		sh.mu.RLock()
+		if keys == nil {
+			keys = make([]K, 0, sh.size*len(c.shards))
+		}
+		for k, en := range sh.items {
+			if !c.isExpired(en) {
+				keys = append(keys, k)
+			}
 		}
+		sh.mu.RUnlock()
 	}
 	return keys
 }
 
 // Sweep removes expired items proactively (not only on access). Returns count removed.
 func (c *Cache[K, V]) Sweep() int {
-	c.mu.Lock()
-	defer c.mu.Unlock()
 	n := 0
-	for k, en := range c.items {
-		if c.isExpired(en) {
-			c.removeKeyLocked(k, en, EvictExpired)
-			n++
+	for _, sh := range c.shards {
+		sh.lock()
+		for k, en := range sh.items {
+			if c.isDead(en) {
+				sh.removeKeyLocked(k, en, EvictExpired)
+				n++
+			}
 		}
+		sh.unlock()
 	}
 	return n
 }
 
 // Clear removes all items and notifies OnEvict with reason=clear.
 func (c *Cache[K, V]) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.cfg.OnEvict != nil {
-		for k, en := range c.items {
-			c.cfg.OnEvict(any(k), any(en.val), EvictClear)
+	for _, sh := range c.shards {
+		sh.lock()
+		for k, en := range sh.items {
+			c.stats.evicted(EvictClear)
+			sh.recordLocked(EventEvict, k, en.val, EvictClear)
 		}
+		sh.items = make(map[K]*entry[K, V])
+		sh.tags = nil
+		sh.loads = nil
+		if sh.wheel != nil {
+			sh.wheel = newTimerWheel[K, V](c.cfg.ExpirationResolution, c.clock.Now())
+		}
+		if sh.policy != nil {
+			sh.policy = c.newPolicy(sh.maxItems)
+		}
+		sh.size = 0
+		sh.cost = 0
+		sh.unlock()
 	}
-	c.items = make(map[K]*entry[V])
-	c.index = make(map[K]*list.Element)
-	if c.ll != nil {
-		c.ll.Init()
-	}
-	c.size = 0
+}
+
+// Close stops the background janitor and snapshotter, waiting for
+// in-progress work to finish, then writes a final snapshot if SnapshotPath
+// is set and waits for queued AsyncEvents to be delivered. The cache stays
+// usable afterwards. Safe to call multiple times.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		if c.quit != nil {
+			close(c.quit)
+			c.bg.Wait()
+		}
+		if c.cfg.SnapshotPath != "" {
+			c.autoSnapshot()
+		}
+	})
+	c.events.wait()
 }
 
 // -------- internals --------
 
-func (c *Cache[K, V]) isExpired(en *entry[V]) bool {
-	return en != nil && !en.expiresAt.IsZero() && c.now().After(en.expiresAt)
+// every runs fn on each tick of interval until Close.
+func (c *Cache[K, V]) every(interval time.Duration, fn func()) {
+	tick, stop := c.clock.NewTicker(interval)
+	c.bg.Add(1)
+	go func() {
+		defer c.bg.Done()
+		defer stop()
+		for {
+			select {
+			case <-tick:
+				fn()
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Cache[K, V]) shardFor(key K) *cacheShard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[c.hash(key)%uint64(len(c.shards))]
+}
+
+// costOf resolves an entry cost: explicit if >=0, else CacheOptions.Cost, else 1.
+func (c *Cache[K, V]) costOf(key K, val V, cost int64) int64 {
+	switch {
+	case cost >= 0:
+		return cost
+	case c.cost != nil:
+		return c.cost(key, val)
+	}
+	return 1
+}
+
+// deadline converts a ttl (Set semantics) into an absolute expiration time.
+func (c *Cache[K, V]) deadline(ttl time.Duration) time.Time {
+	switch {
+	case ttl < 0:
+		// immortal
+	case ttl == 0 && c.cfg.DefaultTTL > 0:
+		return c.clock.Now().Add(c.jitter(c.cfg.DefaultTTL))
+	case ttl > 0:
+		return c.clock.Now().Add(c.jitter(ttl))
+	}
+	return time.Time{}
+}
+
+// jitter shortens ttl by a random share of up to TTLJitter.
+func (c *Cache[K, V]) jitter(ttl time.Duration) time.Duration {
+	if c.cfg.TTLJitter <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(float64(ttl)*min(c.cfg.TTLJitter, 1)*c.random())
 }
 
-func (c *Cache[K, V]) expiredLocked(key K) bool {
-	en, ok := c.items[key]
+// newEntry builds an entry written now; idle>0 makes it sliding, with the
+// ttl deadline as its cap.
+func (c *Cache[K, V]) newEntry(key K, val V, cost int64, ttl, idle time.Duration) *entry[K, V] {
+	now := c.clock.Now()
+	en := &entry[K, V]{key: key, val: val, writtenAt: now, cost: c.costOf(key, val, cost), idle: idle}
+	c.retime(en, ttl, now)
+	return en
+}
+
+// retime gives en a new ttl (Set semantics) counted from now.
+func (c *Cache[K, V]) retime(en *entry[K, V], ttl time.Duration, now time.Time) {
+	en.expiresAt = c.deadline(ttl)
+	en.maxExpiresAt = en.expiresAt
+	en.slide(now)
+}
+
+// expireAfterWrite lets Expiry choose the TTL of en, which replaces old
+// (nil or expired for a fresh insert).
+func (c *Cache[K, V]) expireAfterWrite(key K, en, old *entry[K, V]) {
+	now := en.writtenAt
+	if old == nil || c.isExpired(old) {
+		c.retime(en, c.expiry.AfterCreate(key, en.val, now), now)
+		return
+	}
+	c.retime(en, c.expiry.AfterUpdate(key, en.val, now, old.remaining(now)), now)
+}
+
+func (c *Cache[K, V]) expireAfterRead(key K, en *entry[K, V]) {
+	if c.expiry == nil {
+		return
+	}
+	now := c.clock.Now()
+	current := en.remaining(now)
+	if ttl := c.expiry.AfterRead(key, en.val, now, current); ttl != current {
+		c.retime(en, ttl, now)
+	}
+}
+
+// remaining reports the time left before en expires as a ttl: -1 when it
+// never expires, and at least 1ns otherwise so it never reads as "default".
+func (en *entry[K, V]) remaining(now time.Time) time.Duration {
+	if en.expiresAt.IsZero() {
+		return -1
+	}
+	return max(en.expiresAt.Sub(now), time.Nanosecond)
+}
+
+// slide moves a sliding entry's expiration to now+idle, within its cap.
+func (en *entry[K, V]) slide(now time.Time) {
+	if en.idle <= 0 {
+		return
+	}
+	en.expiresAt = now.Add(en.idle)
+	if !en.maxExpiresAt.IsZero() && en.maxExpiresAt.Before(en.expiresAt) {
+		en.expiresAt = en.maxExpiresAt
+	}
+}
+
+func (c *Cache[K, V]) isExpired(en *entry[K, V]) bool {
+	return en != nil && !en.expiresAt.IsZero() && c.clock.Now().After(en.expiresAt)
+}
+
+// isDead reports whether an expired entry is also past every stale window
+// and can be dropped for good.
+func (c *Cache[K, V]) isDead(en *entry[K, V]) bool {
+	return en != nil && !en.expiresAt.IsZero() && c.clock.Now().After(en.expiresAt.Add(c.grace()))
+}
+
+// grace is how long expired entries are kept for the stale windows.
+func (c *Cache[K, V]) grace() time.Duration {
+	return max(c.cfg.StaleWhileRevalidate, c.cfg.StaleIfError)
+}
+
+// expiredLocked reports whether key holds an expired entry, removing it
+// unless a stale window still needs it.
+func (sh *cacheShard[K, V]) expiredLocked(key K) bool {
+	en, ok := sh.items[key]
 	if !ok {
 		return false
 	}
-	if c.isExpired(en) {
-		c.removeKeyLocked(key, en, EvictExpired)
+	if sh.c.isExpired(en) {
+		if sh.c.isDead(en) {
+			sh.removeKeyLocked(key, en, EvictExpired)
+		}
 		return true
 	}
 	return false
 }
 
-func (c *Cache[K, V]) removeKeyLocked(key K, en *entry[V], reason EvictReason) {
-	delete(c.items, key)
-	c.size--
-	c.removeLRUKeyLocked(key)
-	if c.cfg.OnEvict != nil {
-		c.cfg.OnEvict(any(key), any(en.val), reason)
+func (sh *cacheShard[K, V]) removeKeyLocked(key K, en *entry[K, V], reason EvictReason) {
+	delete(sh.items, key)
+	sh.size--
+	sh.cost -= en.cost
+	sh.untagLocked(key, en)
+	sh.unscheduleLocked(en)
+	if sh.policy != nil {
+		sh.policy.Remove(key)
 	}
+	sh.c.stats.evicted(reason)
+	kind := EventEvict
+	if reason == EvictManual {
+		kind = EventDelete
+		sh.supersedeLocked(key)
+	}
+	sh.recordLocked(kind, key, en.val, reason)
 }
 
-func (c *Cache[K, V]) sizeAliveLocked() int {
+func (sh *cacheShard[K, V]) sizeAliveLocked() int {
 	n := 0
-	for _, en := range c.items {
-		if !c.isExpired(en) {
+	for _, en := range sh.items {
+		if !sh.c.isExpired(en) {
 			n++
 		}
 	}
 	return n
 }
 
-func (c *Cache[K, V]) touchLRULocked(key K) {
-	if c.ll == nil {
+func (sh *cacheShard[K, V]) insertLocked(key K, en *entry[K, V]) {
+	sh.items[key] = en
+	sh.size++
+	sh.cost += en.cost
+	sh.tagLocked(key, en)
+	sh.scheduleLocked(en)
+	if sh.policy != nil {
+		sh.policy.Add(key)
+	}
+	sh.recordLocked(EventInsert, key, en.val, "")
+}
+
+// accessLocked records a read or write of key, sliding its expiration.
+func (sh *cacheShard[K, V]) accessLocked(key K) {
+	if sh.policy != nil {
+		sh.policy.Access(key)
+	}
+	en := sh.items[key]
+	if en.idle > 0 {
+		en.slide(sh.c.clock.Now())
+	}
+	sh.scheduleLocked(en)
+}
+
+// enforceCapacityLocked evicts until the shard fits MaxItems and MaxCost.
+// written is the key just stored; if it alone exceeds the cost budget it is
+// the one evicted.
+func (sh *cacheShard[K, V]) enforceCapacityLocked(written K) {
+	if sh.policy == nil {
 		return
 	}
-	if el, ok := c.index[key]; ok {
-		c.ll.MoveToFront(el)
+	if sh.maxCost > 0 {
+		if en, ok := sh.items[written]; ok && en.cost > sh.c.cfg.MaxCost {
+			sh.removeKeyLocked(written, en, EvictCapacity)
+			return
+		}
+	}
+	if !sh.overCapacityLocked() {
 		return
 	}
-	el := c.ll.PushFront(key)
-	c.index[key] = el
+	// First drop expired quickly
+	for k, en := range sh.items {
+		if sh.c.isExpired(en) {
+			sh.removeKeyLocked(k, en, EvictExpired)
+		}
+	}
+	// Then let the policy pick victims while still over capacity
+	for sh.overCapacityLocked() {
+		key, ok := sh.policy.Evict()
+		if !ok {
+			break
+		}
+		if en, ok := sh.items[key]; ok {
+			sh.removeKeyLocked(key, en, EvictCapacity)
+		}
+	}
 }
 
-func (c *Cache[K, V]) removeLRUKeyLocked(key K) {
-	if c.ll == nil {
-		return
+func (sh *cacheShard[K, V]) overCapacityLocked() bool {
+	// a single entry may exceed the shard's share of MaxCost
+	return (sh.maxItems > 0 && sh.size > sh.maxItems) || (sh.maxCost > 0 && sh.cost > sh.maxCost && sh.size > 1)
+}
+
+// lookup attempts a read without taking the write lock, then acquires the
+// write lock to record the access and classify the entry for GetOrSet.
+func (c *Cache[K, V]) lookup(key K) (V, lookupState) {
+	var zero V
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	en, ok := sh.items[key]
+	usable := ok && (!c.isExpired(en) || !c.isDead(en))
+	sh.mu.RUnlock()
+	if !usable {
+		return zero, lookupMiss
+	}
+
+	sh.lock()
+	defer sh.unlock()
+	sh.expiredLocked(key)
+	en, ok = sh.items[key]
+	if !ok {
+		return zero, lookupMiss
 	}
-	if el, ok := c.index[key]; ok {
-		c.ll.Remove(el)
-		delete(c.index, key)
+	now := c.clock.Now()
+	if c.isExpired(en) {
+		if now.Before(en.expiresAt.Add(c.cfg.StaleWhileRevalidate)) {
+			return en.val, lookupStale
+		}
+		return en.val, lookupStaleIfError
 	}
+	sh.accessLocked(key)
+	c.expireAfterRead(key, en)
+	sh.scheduleLocked(en)
+	if c.cfg.RefreshAfter > 0 && now.Sub(en.writtenAt) >= c.cfg.RefreshAfter {
+		return en.val, lookupRefresh
+	}
+	return en.val, lookupHit
 }
 
-func (c *Cache[K, V]) enforceCapacityLocked() {
-	if c.ll == nil || c.cfg.MaxItems <= 0 {
+// loadShared joins the in-flight load for key or starts one, then waits.
+func (c *Cache[K, V]) loadShared(ctx context.Context, key K, supplier func() (V, time.Duration, error)) (V, error) {
+	c.callMu.Lock()
+	if cl, ok := c.calls[key]; ok {
+		c.callMu.Unlock()
+		return cl.wait(ctx)
+	}
+	// a load may have completed between lookup and taking callMu
+	if v, state := c.lookup(key); state == lookupHit || state == lookupRefresh {
+		c.callMu.Unlock()
+		return v, nil
+	}
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.callMu.Unlock()
+
+	if ctx.Done() == nil {
+		// not cancellable: load inline and skip the goroutine
+		c.load(key, cl, supplier)
+		if cl.panic != nil {
+			panic(cl.panic)
+		}
+	} else {
+		go c.load(key, cl, supplier)
+	}
+	return cl.wait(ctx)
+}
+
+// refresh starts a background load for key unless one is already running.
+func (c *Cache[K, V]) refresh(key K, supplier func() (V, time.Duration, error)) {
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+	if _, ok := c.calls[key]; ok {
 		return
 	}
-	// First drop expired quickly
-	for k, en := range c.items {
-		if c.isExpired(en) {
-			c.removeKeyLocked(k, en, EvictExpired)
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[key] = cl
+	go c.load(key, cl, supplier)
+}
+
+// load runs supplier for key, stores a successful result and wakes waiters.
+// The value is stored before the call is unregistered so later callers
+// either join the call or find the value. It is not stored if the key was
+// written or deleted while supplier ran. A panicking supplier fails the
+// call with ErrSupplierPanic.
+func (c *Cache[K, V]) load(key K, cl *call[V], supplier func() (V, time.Duration, error)) {
+	sh := c.shardFor(key)
+	sh.lock()
+	if sh.loads == nil {
+		sh.loads = make(map[K]*call[V])
+	}
+	sh.loads[key] = cl
+	sh.unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.stats.loadFailure.Add(1)
+			cl.err, cl.panic = fmt.Errorf("%w: %v", ErrSupplierPanic, r), r
 		}
+		sh.lock()
+		if sh.loads[key] == cl {
+			delete(sh.loads, key)
+		}
+		sh.unlock()
+
+		c.callMu.Lock()
+		delete(c.calls, key)
+		c.callMu.Unlock()
+		close(cl.done)
+	}()
+
+	start := c.clock.Now()
+	val, ttl, err := supplier()
+	c.stats.loadNanos.Add(int64(c.clock.Now().Sub(start)))
+	if err == nil {
+		c.stats.loadSuccess.Add(1)
+		sh.lock()
+		if sh.loads[key] == cl {
+			sh.storeLocked(key, c.newEntry(key, val, -1, ttl, c.cfg.ExpireAfterAccess), ttl == 0 && c.expiry != nil)
+		}
+		sh.unlock()
+	} else {
+		c.stats.loadFailure.Add(1)
 	}
-	// Then evict by LRU if still over capacity
-	for c.size > c.cfg.MaxItems {
-		el := c.ll.Back()
-		if el == nil {
-			break
+	cl.val, cl.err = val, err
+}
+
+// loadAll runs a bulk supplier for keys and stores the values it returns
+// with ttl. Like load, a key written or deleted while supplier ran keeps
+// that write. The whole call counts as one load in Stats.
+func (c *Cache[K, V]) loadAll(keys []K, ttl time.Duration, supplier func() (map[K]V, error)) (map[K]V, error) {
+	cl := &call[V]{}
+	for _, key := range keys {
+		sh := c.shardFor(key)
+		sh.lock()
+		if sh.loads == nil {
+			sh.loads = make(map[K]*call[V])
+		}
+		sh.loads[key] = cl
+		sh.unlock()
+	}
+	defer func() {
+		for _, key := range keys {
+			sh := c.shardFor(key)
+			sh.lock()
+			if sh.loads[key] == cl {
+				delete(sh.loads, key)
+			}
+			sh.unlock()
 		}
-		key := el.Value.(K)
-		en := c.items[key]
-		if en == nil {
-			c.ll.Remove(el)
-			delete(c.index, key)
+	}()
+
+	start := c.clock.Now()
+	vals, err := supplier()
+	c.stats.loadNanos.Add(int64(c.clock.Now().Sub(start)))
+	if err != nil {
+		c.stats.loadFailure.Add(1)
+		return nil, err
+	}
+	c.stats.loadSuccess.Add(1)
+	for _, key := range keys {
+		v, ok := vals[key]
+		if !ok {
 			continue
 		}
-		c.removeKeyLocked(key, en, EvictCapacity)
+		sh := c.shardFor(key)
+		sh.lock()
+		if sh.loads[key] == cl {
+			sh.storeLocked(key, c.newEntry(key, v, -1, ttl, c.cfg.ExpireAfterAccess), ttl == 0 && c.expiry != nil)
+		}
+		sh.unlock()
+	}
+	return vals, nil
+}
+
+func (cl *call[V]) wait(ctx context.Context) (V, error) {
+	select {
+	case <-cl.done:
+		if cl.err != nil {
+			var zero V
+			return zero, cl.err
+		}
+		return cl.val, nil
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
 	}
 }
 
-// peek attempts a read without taking the write lock,
-// then acquires write lock to move the key to MRU if still valid.
-func (c *Cache[K, V]) peek(key K) (V, bool) {
-	c.mu.RLock()
-	en, ok := c.items[key]
-	exp := ok && c.isExpired(en)
+// retained reports whether key has an entry that is live or still kept for
+// the stale windows.
+func (c *Cache[K, V]) retained(key K) bool {
+	sh := c.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	en, ok := sh.items[key]
+	return ok && !c.isDead(en)
+}
+
+// supersedeLocked keeps an in-flight load from storing over a write to key.
+func (sh *cacheShard[K, V]) supersedeLocked(key K) {
+	delete(sh.loads, key)
+}
+
+func (sh *cacheShard[K, V]) hasLocked(key K) bool {
+	en, ok := sh.items[key]
+	return ok && !sh.c.isExpired(en)
+}
+
+func (sh *cacheShard[K, V]) storeLocked(key K, en *entry[K, V], useExpiry bool) {
+	c := sh.c
+	c.stats.sets.Add(1)
+	sh.supersedeLocked(key)
+	en.version = c.version.Add(1)
+	old, ok := sh.items[key]
+	if useExpiry {
+		c.expireAfterWrite(key, en, old)
+	}
+	if ok {
+		sh.cost -= old.cost
+		sh.untagLocked(key, old)
+		sh.unscheduleLocked(old)
+		sh.items[key] = en
+		sh.cost += en.cost
+		sh.tagLocked(key, en)
+		sh.scheduleLocked(en)
+		sh.accessLocked(key)
+		sh.recordLocked(EventUpdate, key, en.val, "")
+	} else {
+		sh.insertLocked(key, en)
+	}
+	sh.enforceCapacityLocked(key)
+}
+
+func (sh *cacheShard[K, V]) getLocked(key K) (V, bool) {
+	c := sh.c
 	var zero V
-	c.mu.RUnlock()
-	if !ok || exp {
+	if sh.expiredLocked(key) {
+		c.stats.misses.Add(1)
 		return zero, false
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.expiredLocked(key) {
+	en, ok := sh.items[key]
+	if !ok {
+		c.stats.misses.Add(1)
 		return zero, false
 	}
-	en = c.items[key]
-	c.touchLRULocked(key)
+	c.stats.hits.Add(1)
+	sh.accessLocked(key)
+	c.expireAfterRead(key, en)
+	sh.scheduleLocked(en)
 	return en.val, true
 }
 
-func (c *Cache[K, V]) hasLocked(key K) bool {
-	en, ok := c.items[key]
-	return ok && !c.isExpired(en)
+// addLocked inserts key unless it holds a live entry, returning the new
+// entry and whether it was stored.
+func (sh *cacheShard[K, V]) addLocked(key K, val V, ttl time.Duration) (*entry[K, V], bool) {
+	c := sh.c
+	if en, ok := sh.items[key]; ok {
+		if !c.isExpired(en) {
+			return nil, false
+		}
+		// expired (possibly kept as stale): treat as new insert
+		sh.removeKeyLocked(key, en, EvictExpired)
+	}
+	c.stats.sets.Add(1)
+	sh.supersedeLocked(key)
+	en := c.newEntry(key, val, -1, ttl, c.cfg.ExpireAfterAccess)
+	if ttl == 0 && c.expiry != nil {
+		c.expireAfterWrite(key, en, nil)
+	}
+	en.version = c.version.Add(1)
+	sh.insertLocked(key, en)
+	sh.enforceCapacityLocked(key)
+	return en, true
+}
+
+// changedLocked finishes an in-place value change of a live entry.
+func (sh *cacheShard[K, V]) changedLocked(key K, en *entry[K, V]) {
+	c := sh.c
+	if c.cost != nil {
+		sh.cost -= en.cost
+		en.cost = c.costOf(key, en.val, -1)
+		sh.cost += en.cost
+	}
+	if c.expiry != nil {
+		now := c.clock.Now()
+		c.retime(en, c.expiry.AfterUpdate(key, en.val, now, en.remaining(now)), now)
+	}
+	en.version = c.version.Add(1)
+	sh.supersedeLocked(key)
+	sh.accessLocked(key)
+	sh.recordLocked(EventUpdate, key, en.val, "")
+	sh.enforceCapacityLocked(key)
 }
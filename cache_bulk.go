@@ -0,0 +1,132 @@
+package polyfill
+
+import (
+	"iter"
+	"time"
+)
+
+// CacheEntry is a copy of one live cache entry.
+type CacheEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	ExpiresAt time.Time // zero => no expiration
+	Cost      int64
+	Version   uint64
+}
+
+// GetMany returns the live values for keys, taking each shard lock once.
+// Like Get it counts hits/misses and records the reads for eviction.
+func (c *Cache[K, V]) GetMany(keys []K) map[K]V {
+	out := make(map[K]V, len(keys))
+	for sh, ks := range c.groupByShard(keys) {
+		sh.lock()
+		for _, k := range ks {
+			if v, ok := sh.getLocked(k); ok {
+				out[k] = v
+			}
+		}
+		sh.unlock()
+	}
+	return out
+}
+
+// SetMany stores every item with the same ttl (semantics like Set),
+// taking each shard lock once.
+func (c *Cache[K, V]) SetMany(items map[K]V, ttl time.Duration) {
+	keys := make([]K, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	for sh, ks := range c.groupByShard(keys) {
+		sh.lock()
+		for _, k := range ks {
+			sh.storeLocked(k, c.newEntry(k, items[k], -1, ttl, c.cfg.ExpireAfterAccess), ttl == 0 && c.expiry != nil)
+		}
+		sh.unlock()
+	}
+}
+
+// DeleteMany removes keys and returns how many existed.
+func (c *Cache[K, V]) DeleteMany(keys ...K) int {
+	n := 0
+	for sh, ks := range c.groupByShard(keys) {
+		sh.lock()
+		for _, k := range ks {
+			sh.supersedeLocked(k)
+			if en, ok := sh.items[k]; ok {
+				sh.removeKeyLocked(k, en, EvictManual)
+				n++
+			}
+		}
+		sh.unlock()
+	}
+	return n
+}
+
+// All iterates over live entries without affecting recency or stats.
+// Each shard is copied under its lock and yielded after releasing it, so
+// the loop body may use the cache; changes made meanwhile may or may not
+// be seen.
+//
+// Example:
+//
+//	for k, v := range cache.All() {
+//		fmt.Println(k, v)
+//	}
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, sh := range c.shards {
+			for _, e := range sh.entries() {
+				if !yield(e.Key, e.Value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Entries returns a Seq of copies of the live entries, for reporting with
+// the usual Seq operations. Recency and stats are unaffected.
+//
+// Example:
+//
+//	expiring := cache.Entries().
+//		Filter(func(e CacheEntry[string, int]) bool { return !e.ExpiresAt.IsZero() }).
+//		Len()
+func (c *Cache[K, V]) Entries() *Seq[CacheEntry[K, V]] {
+	var out []CacheEntry[K, V]
+	for _, sh := range c.shards {
+		out = append(out, sh.entries()...)
+	}
+	return From(out)
+}
+
+// -------- internals --------
+
+// groupByShard buckets keys by shard so bulk operations lock each once.
+func (c *Cache[K, V]) groupByShard(keys []K) map[*cacheShard[K, V]][]K {
+	groups := make(map[*cacheShard[K, V]][]K)
+	for _, k := range keys {
+		sh := c.shardFor(k)
+		groups[sh] = append(groups[sh], k)
+	}
+	return groups
+}
+
+func (sh *cacheShard[K, V]) entries() []CacheEntry[K, V] {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	out := make([]CacheEntry[K, V], 0, len(sh.items))
+	for k, en := range sh.items {
+		if !sh.c.isExpired(en) {
+			out = append(out, CacheEntry[K, V]{
+				Key:       k,
+				Value:     en.val,
+				ExpiresAt: en.expiresAt,
+				Cost:      en.cost,
+				Version:   en.version,
+			})
+		}
+	}
+	return out
+}
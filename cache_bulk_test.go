@@ -0,0 +1,84 @@
+package polyfill
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestBulk_GetSetDeleteMany(t *testing.T) {
+	c := NewCache[string, int](Config{Shards: 4})
+	c.SetMany(map[string]int{"a": 1, "b": 2, "c": 3}, -1)
+
+	got := c.GetMany([]string{"a", "c", "missing"})
+	if want := map[string]int{"a": 1, "c": 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 1 || s.Sets != 3 {
+		t.Fatalf("expected bulk ops to count like single ones, got %+v", s)
+	}
+	if n := c.DeleteMany("a", "b", "missing"); n != 2 {
+		t.Fatalf("expected 2 deletions, got %d", n)
+	}
+	if c.Len() != 1 || !c.Has("c") {
+		t.Fatalf("unexpected contents: %v", c.Keys())
+	}
+}
+
+func TestAll_DoesNotTouchRecency(t *testing.T) {
+	var evicted []any
+	clk := fakeclock.New(time.Now())
+	c := NewCache[string, int](Config{
+		MaxItems: 3,
+		OnEvict:  func(k, _ any, _ EvictReason) { evicted = append(evicted, k) },
+		Clock:    clk,
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.Set("gone", 3, time.Second)
+	clk.Advance(time.Minute)
+
+	got := maps.Collect(c.All())
+	if want := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected live entries %v, got %v", want, got)
+	}
+	for range c.All() {
+		c.Delete("gone") // the loop body may use the cache
+		break
+	}
+	c.Set("c", 4, -1)
+	c.Set("d", 5, -1) // over capacity: a is still the least recent
+	if !reflect.DeepEqual(evicted, []any{"gone", "a"}) {
+		t.Fatalf("expected iteration to leave recency alone, evicted %v", evicted)
+	}
+	if s := c.Stats(); s.Hits != 0 {
+		t.Fatalf("iteration must not count as hits, got %d", s.Hits)
+	}
+}
+
+func TestEntries_Seq(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	c.Set("a", 1, -1)
+	c.Set("b", 20, time.Hour)
+	c.Set("c", 30, -1)
+	big := c.Entries().
+		Filter(func(e CacheEntry[string, int]) bool { return e.Value >= 20 }).
+		Slice()
+	keys := make([]string, 0, len(big))
+	for _, e := range big {
+		keys = append(keys, e.Key)
+	}
+	slices.Sort(keys)
+	if !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Fatalf("expected b and c, got %v", keys)
+	}
+	for _, e := range big {
+		if e.Key == "b" && e.ExpiresAt.IsZero() {
+			t.Fatalf("expected expiration to be reported")
+		}
+	}
+}
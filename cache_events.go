@@ -0,0 +1,168 @@
+package polyfill
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CacheEventKind classifies a CacheEvent.
+type CacheEventKind string
+
+const (
+	EventInsert CacheEventKind = "insert" // a new key was stored
+	EventUpdate CacheEventKind = "update" // an existing key got a new value
+	EventDelete CacheEventKind = "delete" // a key was removed by the caller
+	EventEvict  CacheEventKind = "evict"  // the cache dropped a key on its own
+)
+
+// CacheEvent describes one change to a cache. Reason is set for deletes
+// (EvictManual) and evictions.
+type CacheEvent[K comparable, V any] struct {
+	Kind   CacheEventKind
+	Key    K
+	Value  V
+	Reason EvictReason
+}
+
+// Subscribe returns a channel receiving every insert, update, delete and
+// eviction, and a cancel func that stops delivery and closes the channel.
+// Events are dropped when the channel's buffer is full, so a slow
+// subscriber never stalls the cache.
+//
+// Example:
+//
+//	events, cancel := cache.Subscribe(128)
+//	defer cancel()
+//	for ev := range events {
+//		log.Printf("%s %v", ev.Kind, ev.Key)
+//	}
+func (c *Cache[K, V]) Subscribe(buffer int) (<-chan CacheEvent[K, V], func()) {
+	ch := make(chan CacheEvent[K, V], buffer)
+	h := &c.events
+	h.subMu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan CacheEvent[K, V]]struct{})
+	}
+	h.subs[ch] = struct{}{}
+	h.nsubs.Add(1)
+	h.subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.subMu.Lock()
+			delete(h.subs, ch)
+			h.nsubs.Add(-1)
+			close(ch)
+			h.subMu.Unlock()
+		})
+	}
+}
+
+// -------- internals --------
+
+// eventHub fans events out to listeners and subscribers, optionally
+// through an ordered background queue.
+type eventHub[K comparable, V any] struct {
+	subMu sync.RWMutex
+	subs  map[chan CacheEvent[K, V]]struct{}
+	nsubs atomic.Int32
+
+	// AsyncEvents queue, drained by at most one goroutine at a time
+	qMu     sync.Mutex
+	queue   []CacheEvent[K, V]
+	running bool
+	idle    sync.Cond
+}
+
+// lock takes the shard's write lock; pair it with unlock so events
+// recorded meanwhile are delivered once the lock is released.
+func (sh *cacheShard[K, V]) lock() {
+	sh.mu.Lock()
+}
+
+func (sh *cacheShard[K, V]) unlock() {
+	events := sh.pending
+	sh.pending = nil
+	if len(events) > 0 && sh.c.cfg.AsyncEvents {
+		// enqueue before unlocking to keep per-shard order
+		sh.c.enqueue(events)
+		events = nil
+	}
+	sh.mu.Unlock()
+	sh.c.deliver(events)
+}
+
+// recordLocked queues an event for delivery if anyone is listening for it.
+func (sh *cacheShard[K, V]) recordLocked(kind CacheEventKind, key K, val V, reason EvictReason) {
+	c := sh.c
+	removal := kind == EventDelete || kind == EventEvict
+	if c.events.nsubs.Load() == 0 && !(removal && (c.cfg.OnEvict != nil || c.onEvict != nil)) {
+		return
+	}
+	sh.pending = append(sh.pending, CacheEvent[K, V]{Kind: kind, Key: key, Value: val, Reason: reason})
+}
+
+func (c *Cache[K, V]) deliver(events []CacheEvent[K, V]) {
+	for _, ev := range events {
+		if ev.Kind == EventDelete || ev.Kind == EventEvict {
+			if c.cfg.OnEvict != nil {
+				c.cfg.OnEvict(any(ev.Key), any(ev.Value), ev.Reason)
+			}
+			if c.onEvict != nil {
+				c.onEvict(ev.Key, ev.Value, ev.Reason)
+			}
+		}
+		if c.events.nsubs.Load() > 0 {
+			c.events.publish(ev)
+		}
+	}
+}
+
+func (h *eventHub[K, V]) publish(ev CacheEvent[K, V]) {
+	h.subMu.RLock()
+	defer h.subMu.RUnlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (c *Cache[K, V]) enqueue(events []CacheEvent[K, V]) {
+	h := &c.events
+	h.qMu.Lock()
+	defer h.qMu.Unlock()
+	h.queue = append(h.queue, events...)
+	if !h.running {
+		h.running = true
+		go c.drain()
+	}
+}
+
+func (c *Cache[K, V]) drain() {
+	h := &c.events
+	for {
+		h.qMu.Lock()
+		batch := h.queue
+		h.queue = nil
+		if len(batch) == 0 {
+			h.running = false
+			h.idle.Broadcast()
+			h.qMu.Unlock()
+			return
+		}
+		h.qMu.Unlock()
+		c.deliver(batch)
+	}
+}
+
+// wait blocks until the async queue is empty.
+func (h *eventHub[K, V]) wait() {
+	h.qMu.Lock()
+	defer h.qMu.Unlock()
+	for h.running {
+		h.idle.Wait()
+	}
+}
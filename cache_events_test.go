@@ -0,0 +1,108 @@
+package polyfill
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestEvictListener_TypedAndReentrant(t *testing.T) {
+	var c *Cache[string, int]
+	type evicted struct {
+		key    string
+		val    int
+		reason EvictReason
+	}
+	var got []evicted
+	c = NewCacheWith(CacheOptions[string, int]{
+		Config: Config{
+			MaxItems: 2,
+			// the legacy callback is also released from the lock
+			OnEvict: func(any, any, EvictReason) { c.Len() },
+		},
+		EvictListener: func(k string, v int, r EvictReason) {
+			got = append(got, evicted{k, v, r})
+			if r == EvictCapacity {
+				c.Set("last-evicted", v, -1) // would deadlock under the lock
+			}
+		},
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.Set("c", 3, -1) // evicts a, then the listener's write evicts b
+	c.Delete("c")
+
+	want := []evicted{{"a", 1, EvictCapacity}, {"b", 2, EvictCapacity}, {"c", 3, EvictManual}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if v, ok := c.Get("last-evicted"); !ok || v != 2 {
+		t.Fatalf("expected listener write to land, got %v %v", v, ok)
+	}
+}
+
+func TestSubscribe_StreamsAllChanges(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	c := NewCache[string, int](Config{MaxItems: 1, Clock: clk})
+	events, cancel := c.Subscribe(16)
+
+	c.Set("a", 1, time.Second)
+	c.Set("a", 2, time.Second)
+	c.Update("a", func(v *int) error { *v++; return nil })
+	c.Set("b", 1, -1) // capacity eviction of a
+	c.Delete("b")
+	c.Set("c", 1, time.Second)
+	clk.Advance(2 * time.Second)
+	c.Get("c")
+	cancel()
+	cancel() // idempotent
+
+	var got []string
+	for ev := range events {
+		got = append(got, string(ev.Kind)+":"+ev.Key+":"+string(ev.Reason))
+	}
+	want := []string{
+		"insert:a:", "update:a:", "update:a:",
+		"insert:b:", "evict:a:capacity",
+		"delete:b:manual",
+		"insert:c:", "evict:c:expired",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSubscribe_DropsWhenFull(t *testing.T) {
+	c := NewCache[int, int](Config{})
+	events, cancel := c.Subscribe(2)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		c.Set(i, i, -1)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected a full buffer of 2, got %d", len(events))
+	}
+}
+
+func TestAsyncEvents_DeliveredInOrderOffTheCaller(t *testing.T) {
+	block := make(chan struct{})
+	var keys []int
+	c := NewCacheWith(CacheOptions[int, int]{
+		Config: Config{AsyncEvents: true},
+		EvictListener: func(k, _ int, _ EvictReason) {
+			<-block
+			keys = append(keys, k)
+		},
+	})
+	for i := 0; i < 5; i++ {
+		c.Set(i, i, -1)
+		c.Delete(i) // returns even though the listener is blocked
+	}
+	close(block)
+	c.Close() // waits for the queue to drain
+	if !reflect.DeepEqual(keys, []int{0, 1, 2, 3, 4}) {
+		t.Fatalf("expected ordered async delivery, got %v", keys)
+	}
+}
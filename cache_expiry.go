@@ -0,0 +1,49 @@
+package polyfill
+
+import "time"
+
+// Expiry computes per-entry TTLs, set through CacheOptions.Expiry.
+// Returned durations follow Set's ttl semantics (<0 = never expire,
+// 0 = DefaultTTL); current is the remaining TTL in the same form, so
+// returning it leaves the expiration unchanged. Hooks run under the
+// shard lock and must not call back into the cache.
+type Expiry[K comparable, V any] interface {
+	// AfterCreate returns the TTL of a newly stored entry.
+	AfterCreate(key K, val V, now time.Time) time.Duration
+	// AfterUpdate returns the TTL after a live entry's value is replaced.
+	AfterUpdate(key K, val V, now time.Time, current time.Duration) time.Duration
+	// AfterRead returns the TTL after an entry is read.
+	AfterRead(key K, val V, now time.Time, current time.Duration) time.Duration
+}
+
+// ExpireAt returns an Expiry that expires each entry at the time deadline
+// reports for it (zero = never); reads leave the expiration unchanged.
+//
+// Example:
+//
+//	tokens := NewCacheWith(CacheOptions[string, Token]{
+//		Expiry: ExpireAt(func(_ string, t Token) time.Time { return t.ExpiresAt }),
+//	})
+//	tokens.Set(id, tok, 0) // lives exactly as long as the token
+func ExpireAt[K comparable, V any](deadline func(K, V) time.Time) Expiry[K, V] {
+	return expireAt[K, V](deadline)
+}
+
+type expireAt[K comparable, V any] func(K, V) time.Time
+
+func (f expireAt[K, V]) AfterCreate(key K, val V, now time.Time) time.Duration {
+	d := f(key, val)
+	if d.IsZero() {
+		return -1
+	}
+	// already past: expire on the next check
+	return max(d.Sub(now), time.Nanosecond)
+}
+
+func (f expireAt[K, V]) AfterUpdate(key K, val V, now time.Time, _ time.Duration) time.Duration {
+	return f.AfterCreate(key, val, now)
+}
+
+func (f expireAt[K, V]) AfterRead(_ K, _ V, _ time.Time, current time.Duration) time.Duration {
+	return current
+}
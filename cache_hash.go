@@ -0,0 +1,114 @@
+package polyfill
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// defaultHasher returns a shard hasher for K. Strings and integers are hashed
+// directly; any other comparable type is walked with reflect, hashing what
+// == compares (so -0.0 and +0.0, or structs holding them, hash alike).
+func defaultHasher[K comparable]() func(K) uint64 {
+	seed := maphash.MakeSeed()
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case int:
+			return mix64(uint64(k))
+		case int8:
+			return mix64(uint64(k))
+		case int16:
+			return mix64(uint64(k))
+		case int32:
+			return mix64(uint64(k))
+		case int64:
+			return mix64(uint64(k))
+		case uint:
+			return mix64(uint64(k))
+		case uint8:
+			return mix64(uint64(k))
+		case uint16:
+			return mix64(uint64(k))
+		case uint32:
+			return mix64(uint64(k))
+		case uint64:
+			return mix64(k)
+		case uintptr:
+			return mix64(uint64(k))
+		}
+		var h maphash.Hash
+		h.SetSeed(seed)
+		hashValue(&h, reflect.ValueOf(&key).Elem())
+		return h.Sum64()
+	}
+}
+
+// hashValue feeds v to h so that values equal under == write equal bytes.
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			writeUint64(h, 1)
+		} else {
+			writeUint64(h, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		writeFloat(h, real(v.Complex()))
+		writeFloat(h, imag(v.Complex()))
+	case reflect.String:
+		writeUint64(h, uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint64(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			writeUint64(h, 0)
+			return
+		}
+		hashValue(h, v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name != "_" { // == ignores blank fields
+				hashValue(h, v.Field(i))
+			}
+		}
+	}
+}
+
+func writeFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		f = 0 // -0 == +0
+	}
+	writeUint64(h, math.Float64bits(f))
+}
+
+func writeUint64(h *maphash.Hash, x uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], x)
+	h.Write(b[:])
+}
+
+// mix64 is the splitmix64 finalizer, spreading sequential integers
+// evenly across shards.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,622 @@
+package polyfill
+
+import "container/list"
+
+// PolicyKind selects a built-in eviction policy through Config.Policy.
+type PolicyKind string
+
+const (
+	// PolicyLRU evicts the least recently used entry (default).
+	PolicyLRU PolicyKind = "lru"
+	// PolicyLFU evicts the least frequently used entry, oldest first on ties.
+	PolicyLFU PolicyKind = "lfu"
+	// PolicyFIFO evicts the oldest inserted entry regardless of reads.
+	PolicyFIFO PolicyKind = "fifo"
+	// PolicyARC balances recency and frequency adaptively (Adaptive Replacement Cache).
+	PolicyARC PolicyKind = "arc"
+	// PolicyS3FIFO uses a small probationary FIFO, a main FIFO and a ghost queue.
+	PolicyS3FIFO PolicyKind = "s3fifo"
+	// PolicyTinyLFU is W-TinyLFU: a small LRU window in front of a segmented
+	// LRU whose admissions are filtered by a frequency sketch.
+	PolicyTinyLFU PolicyKind = "tinylfu"
+)
+
+// EvictionPolicy decides which entry leaves a full cache shard.
+// Every method is called with the shard lock held, so implementations
+// need no synchronization of their own.
+type EvictionPolicy[K comparable] interface {
+	// Add records a key that was just inserted.
+	Add(key K)
+	// Access records a read or overwrite of a key already present.
+	Access(key K)
+	// Remove forgets a key the cache dropped on its own (delete, expiry,
+	// clear). Unknown keys must be ignored.
+	Remove(key K)
+	// Evict picks the next victim and stops tracking it.
+	// ok=false means the policy tracks no keys.
+	Evict() (key K, ok bool)
+}
+
+// newPolicy builds a built-in policy for a shard holding up to capacity
+// entries (0 when the limit is not expressed in entries).
+func newPolicy[K comparable](kind PolicyKind, capacity int, hash func(K) uint64) EvictionPolicy[K] {
+	switch kind {
+	case PolicyLFU:
+		return newLFUPolicy[K]()
+	case PolicyFIFO:
+		return &fifoPolicy[K]{lruPolicy: *newLRUPolicy[K]()}
+	case PolicyARC:
+		return newARCPolicy[K](capacity)
+	case PolicyS3FIFO:
+		return newS3FIFOPolicy[K](capacity)
+	case PolicyTinyLFU:
+		return newTinyLFUPolicy(capacity, hash)
+	}
+	return newLRUPolicy[K]()
+}
+
+// -------- LRU --------
+
+// keyList is a recency list with O(1) lookup; front = most recent.
+type keyList[K comparable] struct {
+	ll    *list.List
+	index map[K]*list.Element
+}
+
+func newKeyList[K comparable]() keyList[K] {
+	return keyList[K]{ll: list.New(), index: make(map[K]*list.Element)}
+}
+
+func (l *keyList[K]) has(key K) bool {
+	_, ok := l.index[key]
+	return ok
+}
+
+func (l *keyList[K]) len() int {
+	return l.ll.Len()
+}
+
+func (l *keyList[K]) pushFront(key K) {
+	l.index[key] = l.ll.PushFront(key)
+}
+
+func (l *keyList[K]) moveToFront(key K) bool {
+	el, ok := l.index[key]
+	if ok {
+		l.ll.MoveToFront(el)
+	}
+	return ok
+}
+
+func (l *keyList[K]) remove(key K) bool {
+	el, ok := l.index[key]
+	if ok {
+		l.ll.Remove(el)
+		delete(l.index, key)
+	}
+	return ok
+}
+
+func (l *keyList[K]) popBack() (K, bool) {
+	el := l.ll.Back()
+	if el == nil {
+		var zero K
+		return zero, false
+	}
+	key := el.Value.(K)
+	l.ll.Remove(el)
+	delete(l.index, key)
+	return key, true
+}
+
+// keys returns the tracked keys from least to most recent.
+func (l *keyList[K]) keys() []K {
+	out := make([]K, 0, l.ll.Len())
+	for el := l.ll.Back(); el != nil; el = el.Prev() {
+		out = append(out, el.Value.(K))
+	}
+	return out
+}
+
+type lruPolicy[K comparable] struct {
+	keys keyList[K]
+}
+
+func newLRUPolicy[K comparable]() *lruPolicy[K] {
+	return &lruPolicy[K]{keys: newKeyList[K]()}
+}
+
+func (p *lruPolicy[K]) Add(key K) {
+	if !p.keys.moveToFront(key) {
+		p.keys.pushFront(key)
+	}
+}
+
+func (p *lruPolicy[K]) Access(key K)     { p.keys.moveToFront(key) }
+func (p *lruPolicy[K]) Remove(key K)     { p.keys.remove(key) }
+func (p *lruPolicy[K]) Evict() (K, bool) { return p.keys.popBack() }
+func (p *lruPolicy[K]) order() []K       { return p.keys.keys() }
+
+// -------- FIFO --------
+
+type fifoPolicy[K comparable] struct {
+	lruPolicy[K]
+}
+
+// Access is a no-op: reads never change FIFO order.
+func (p *fifoPolicy[K]) Access(K) {}
+
+// -------- LFU --------
+
+type lfuItem[K comparable] struct {
+	key  K
+	freq int
+}
+
+// lfuPolicy keeps one recency list per frequency so every operation is O(1)
+// apart from recomputing the minimum after removals.
+type lfuPolicy[K comparable] struct {
+	items   map[K]*list.Element
+	freqs   map[int]*list.List
+	minFreq int
+}
+
+func newLFUPolicy[K comparable]() *lfuPolicy[K] {
+	return &lfuPolicy[K]{items: make(map[K]*list.Element), freqs: make(map[int]*list.List)}
+}
+
+func (p *lfuPolicy[K]) Add(key K) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.items[key] = p.bucket(1).PushFront(&lfuItem[K]{key: key, freq: 1})
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy[K]) Access(key K) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	it := el.Value.(*lfuItem[K])
+	p.unlink(el, it.freq)
+	if p.minFreq == it.freq && p.freqs[it.freq] == nil {
+		p.minFreq++
+	}
+	it.freq++
+	p.items[key] = p.bucket(it.freq).PushFront(it)
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.unlink(el, el.Value.(*lfuItem[K]).freq)
+	delete(p.items, key)
+}
+
+func (p *lfuPolicy[K]) Evict() (K, bool) {
+	var zero K
+	if len(p.items) == 0 {
+		return zero, false
+	}
+	if p.freqs[p.minFreq] == nil {
+		// minimum went stale after Remove; recompute it
+		p.minFreq = 0
+		for f := range p.freqs {
+			if p.minFreq == 0 || f < p.minFreq {
+				p.minFreq = f
+			}
+		}
+	}
+	el := p.freqs[p.minFreq].Back()
+	key := el.Value.(*lfuItem[K]).key
+	p.Remove(key)
+	return key, true
+}
+
+func (p *lfuPolicy[K]) bucket(freq int) *list.List {
+	l, ok := p.freqs[freq]
+	if !ok {
+		l = list.New()
+		p.freqs[freq] = l
+	}
+	return l
+}
+
+func (p *lfuPolicy[K]) unlink(el *list.Element, freq int) {
+	l := p.freqs[freq]
+	l.Remove(el)
+	if l.Len() == 0 {
+		delete(p.freqs, freq)
+	}
+}
+
+// -------- ARC --------
+
+// arcPolicy implements Megiddo & Modha's Adaptive Replacement Cache.
+// t1/t2 hold resident keys seen once / more than once; b1/b2 are ghost
+// lists of keys recently evicted from each, steering the target size p.
+type arcPolicy[K comparable] struct {
+	capacity       int
+	p              int
+	t1, t2, b1, b2 keyList[K]
+	fromB2         bool // last Add was a b2 ghost hit
+}
+
+func newARCPolicy[K comparable](capacity int) *arcPolicy[K] {
+	return &arcPolicy[K]{
+		capacity: capacity,
+		t1:       newKeyList[K](),
+		t2:       newKeyList[K](),
+		b1:       newKeyList[K](),
+		b2:       newKeyList[K](),
+	}
+}
+
+func (p *arcPolicy[K]) Add(key K) {
+	p.fromB2 = false
+	c := p.limit()
+	switch {
+	case p.t1.has(key) || p.t2.has(key):
+		p.Access(key)
+		return
+	case p.b1.has(key):
+		p.p = min(c, p.p+max(p.b2.len()/max(p.b1.len(), 1), 1))
+		p.b1.remove(key)
+		p.t2.pushFront(key)
+	case p.b2.has(key):
+		p.p = max(0, p.p-max(p.b1.len()/max(p.b2.len(), 1), 1))
+		p.b2.remove(key)
+		p.t2.pushFront(key)
+		p.fromB2 = true
+	default:
+		p.t1.pushFront(key)
+	}
+	// bound the directory: |t1|+|b1| <= c and total <= 2c
+	for p.t1.len()+p.b1.len() > c && p.b1.len() > 0 {
+		p.b1.popBack()
+	}
+	for p.t1.len()+p.t2.len()+p.b1.len()+p.b2.len() > 2*c && p.b2.len() > 0 {
+		p.b2.popBack()
+	}
+}
+
+func (p *arcPolicy[K]) Access(key K) {
+	if p.t1.remove(key) {
+		p.t2.pushFront(key)
+		return
+	}
+	p.t2.moveToFront(key)
+}
+
+func (p *arcPolicy[K]) Remove(key K) {
+	if !p.t1.remove(key) {
+		p.t2.remove(key)
+	}
+}
+
+func (p *arcPolicy[K]) Evict() (K, bool) {
+	if t1 := p.t1.len(); t1 > 0 && (t1 > p.p || (p.fromB2 && t1 == p.p) || p.t2.len() == 0) {
+		key, _ := p.t1.popBack()
+		p.b1.pushFront(key)
+		return key, true
+	}
+	key, ok := p.t2.popBack()
+	if ok {
+		p.b2.pushFront(key)
+	}
+	return key, ok
+}
+
+// limit is the configured capacity, or the resident size when the shard is
+// limited by something other than entry count.
+func (p *arcPolicy[K]) limit() int {
+	return max(p.capacity, p.t1.len()+p.t2.len()+1)
+}
+
+// -------- S3-FIFO --------
+
+type s3Item[K comparable] struct {
+	key  K
+	freq uint8
+	main bool
+}
+
+// s3fifoPolicy implements S3-FIFO (Yang et al., SOSP'23): new keys enter a
+// small FIFO; keys re-read while there graduate to the main FIFO, the rest
+// are remembered in a ghost queue so a quick return goes straight to main.
+type s3fifoPolicy[K comparable] struct {
+	capacity    int
+	small, main *list.List
+	items       map[K]*list.Element
+	ghost       keyList[K]
+}
+
+func newS3FIFOPolicy[K comparable](capacity int) *s3fifoPolicy[K] {
+	return &s3fifoPolicy[K]{
+		capacity: capacity,
+		small:    list.New(),
+		main:     list.New(),
+		items:    make(map[K]*list.Element),
+		ghost:    newKeyList[K](),
+	}
+}
+
+func (p *s3fifoPolicy[K]) Add(key K) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	if p.ghost.remove(key) {
+		p.items[key] = p.main.PushFront(&s3Item[K]{key: key, main: true})
+		return
+	}
+	p.items[key] = p.small.PushFront(&s3Item[K]{key: key})
+}
+
+func (p *s3fifoPolicy[K]) Access(key K) {
+	if el, ok := p.items[key]; ok {
+		it := el.Value.(*s3Item[K])
+		if it.freq < 3 {
+			it.freq++
+		}
+	}
+}
+
+func (p *s3fifoPolicy[K]) Remove(key K) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	if el.Value.(*s3Item[K]).main {
+		p.main.Remove(el)
+	} else {
+		p.small.Remove(el)
+	}
+	delete(p.items, key)
+}
+
+func (p *s3fifoPolicy[K]) Evict() (K, bool) {
+	for len(p.items) > 0 {
+		total := len(p.items)
+		if p.small.Len() > 0 && (p.small.Len()*10 >= total || p.main.Len() == 0) {
+			if key, ok := p.evictSmall(); ok {
+				return key, true
+			}
+			continue
+		}
+		return p.evictMain()
+	}
+	var zero K
+	return zero, false
+}
+
+// evictSmall drops the small-queue tail into the ghost queue, or moves it
+// to main when it was read while on probation (returning ok=false).
+func (p *s3fifoPolicy[K]) evictSmall() (K, bool) {
+	el := p.small.Back()
+	it := el.Value.(*s3Item[K])
+	p.small.Remove(el)
+	if it.freq > 0 {
+		it.freq, it.main = 0, true
+		p.items[it.key] = p.main.PushFront(it)
+		var zero K
+		return zero, false
+	}
+	delete(p.items, it.key)
+	p.ghost.pushFront(it.key)
+	for limit := max(p.capacity, len(p.items)); p.ghost.len() > limit; {
+		p.ghost.popBack()
+	}
+	return it.key, true
+}
+
+// evictMain runs the main-queue CLOCK: entries with remaining frequency
+// are reinserted with one less credit until one has none left.
+func (p *s3fifoPolicy[K]) evictMain() (K, bool) {
+	for {
+		el := p.main.Back()
+		it := el.Value.(*s3Item[K])
+		if it.freq > 0 {
+			it.freq--
+			p.main.MoveToFront(el)
+			continue
+		}
+		p.main.Remove(el)
+		delete(p.items, it.key)
+		return it.key, true
+	}
+}
+
+// -------- W-TinyLFU --------
+
+type tinyItem[K comparable] struct {
+	key     K
+	hash    uint64
+	segment uint8 // tinyWindow, tinyProbation or tinyProtected
+}
+
+const (
+	tinyWindow uint8 = iota
+	tinyProbation
+	tinyProtected
+)
+
+// tinyLFUPolicy implements W-TinyLFU (Einziger et al.): new keys land in a
+// window LRU holding ~1% of entries. Keys leaving the window must beat the
+// main cache's next victim on estimated frequency to be admitted; the main
+// cache is a segmented LRU with an 80% protected segment.
+type tinyLFUPolicy[K comparable] struct {
+	capacity int
+	hash     func(K) uint64
+	sketch   *countMinSketch
+	items    map[K]*list.Element
+	window   *list.List
+	probat   *list.List
+	protec   *list.List
+}
+
+func newTinyLFUPolicy[K comparable](capacity int, hash func(K) uint64) *tinyLFUPolicy[K] {
+	return &tinyLFUPolicy[K]{
+		capacity: capacity,
+		hash:     hash,
+		sketch:   newCountMinSketch(capacity),
+		items:    make(map[K]*list.Element),
+		window:   list.New(),
+		probat:   list.New(),
+		protec:   list.New(),
+	}
+}
+
+func (p *tinyLFUPolicy[K]) Add(key K) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	h := p.hash(key)
+	p.sketch.increment(h)
+	p.items[key] = p.window.PushFront(&tinyItem[K]{key: key, hash: h})
+}
+
+func (p *tinyLFUPolicy[K]) Access(key K) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	it := el.Value.(*tinyItem[K])
+	p.sketch.increment(it.hash)
+	switch it.segment {
+	case tinyWindow:
+		p.window.MoveToFront(el)
+	case tinyProbation:
+		p.probat.Remove(el)
+		it.segment = tinyProtected
+		p.items[key] = p.protec.PushFront(it)
+		// keep protected at ~80% of main by demoting its LRU
+		for p.protec.Len() > 1 && p.protec.Len()*5 > (p.protec.Len()+p.probat.Len())*4 {
+			back := p.protec.Back()
+			demoted := back.Value.(*tinyItem[K])
+			p.protec.Remove(back)
+			demoted.segment = tinyProbation
+			p.items[demoted.key] = p.probat.PushFront(demoted)
+		}
+	case tinyProtected:
+		p.protec.MoveToFront(el)
+	}
+}
+
+func (p *tinyLFUPolicy[K]) Remove(key K) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.segment(el.Value.(*tinyItem[K]).segment).Remove(el)
+	delete(p.items, key)
+}
+
+func (p *tinyLFUPolicy[K]) Evict() (K, bool) {
+	var zero K
+	if len(p.items) == 0 {
+		return zero, false
+	}
+	capacity := p.capacity
+	if capacity <= 0 {
+		capacity = len(p.items) - 1
+	}
+	windowMax := max(1, capacity/100)
+	mainMax := capacity - windowMax
+	for p.window.Len() > windowMax {
+		candEl := p.window.Back()
+		cand := candEl.Value.(*tinyItem[K])
+		victimEl := p.probat.Back()
+		if victimEl == nil {
+			victimEl = p.protec.Back()
+		}
+		if victimEl == nil || p.probat.Len()+p.protec.Len() < mainMax {
+			// main has room: the window's LRU moves over without a contest
+			p.window.Remove(candEl)
+			cand.segment = tinyProbation
+			p.items[cand.key] = p.probat.PushFront(cand)
+			continue
+		}
+		// the window's LRU competes with main's victim for admission
+		victim := victimEl.Value.(*tinyItem[K])
+		if p.sketch.estimate(cand.hash) > p.sketch.estimate(victim.hash) {
+			p.Remove(victim.key)
+			p.window.Remove(candEl)
+			cand.segment = tinyProbation
+			p.items[cand.key] = p.probat.PushFront(cand)
+			return victim.key, true
+		}
+		p.Remove(cand.key)
+		return cand.key, true
+	}
+	for _, l := range []*list.List{p.probat, p.protec, p.window} {
+		if el := l.Back(); el != nil {
+			key := el.Value.(*tinyItem[K]).key
+			p.Remove(key)
+			return key, true
+		}
+	}
+	return zero, false
+}
+
+func (p *tinyLFUPolicy[K]) segment(s uint8) *list.List {
+	switch s {
+	case tinyProbation:
+		return p.probat
+	case tinyProtected:
+		return p.protec
+	}
+	return p.window
+}
+
+// countMinSketch estimates key frequencies with four rows of saturating
+// counters, halving every counter periodically so old popularity fades.
+type countMinSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 64
+	for width < capacity {
+		width <<= 1
+	}
+	s := &countMinSketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := mix64(h+uint64(i)*0x9e3779b97f4a7c15) & s.mask
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.additions /= 2
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	est := uint8(15)
+	for i := range s.rows {
+		est = min(est, s.rows[i][mix64(h+uint64(i)*0x9e3779b97f4a7c15)&s.mask])
+	}
+	return est
+}
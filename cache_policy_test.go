@@ -0,0 +1,159 @@
+package polyfill
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var allPolicies = []PolicyKind{PolicyLRU, PolicyLFU, PolicyFIFO, PolicyARC, PolicyS3FIFO, PolicyTinyLFU}
+
+func TestPolicies_RespectCapacity(t *testing.T) {
+	for _, kind := range allPolicies {
+		t.Run(string(kind), func(t *testing.T) {
+			evictions := 0
+			c := NewCache[int, int](Config{
+				MaxItems: 50,
+				Policy:   kind,
+				OnEvict: func(_, _ any, r EvictReason) {
+					if r == EvictCapacity {
+						evictions++
+					}
+				},
+			})
+			for i := 0; i < 500; i++ {
+				c.Set(i, i, -1)
+				c.Get(i % 7)
+			}
+			if n := c.Len(); n != 50 {
+				t.Fatalf("expected 50 entries, got %d", n)
+			}
+			if evictions != 450 {
+				t.Fatalf("expected 450 capacity evictions, got %d", evictions)
+			}
+			// deleting then re-adding keeps the policy consistent
+			for _, k := range c.Keys() {
+				c.Delete(k)
+			}
+			for i := 0; i < 60; i++ {
+				c.Set(i, i, -1)
+			}
+			if n := c.Len(); n != 50 {
+				t.Fatalf("expected 50 entries after refill, got %d", n)
+			}
+		})
+	}
+}
+
+func TestPolicyFIFO_IgnoresReads(t *testing.T) {
+	var evicted []any
+	c := NewCache[string, int](Config{
+		MaxItems: 2,
+		Policy:   PolicyFIFO,
+		OnEvict:  func(k, _ any, _ EvictReason) { evicted = append(evicted, k) },
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.Get("a")
+	c.Set("c", 3, -1)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("expected FIFO to evict 'a', got %v", evicted)
+	}
+}
+
+func TestPolicyLFU_EvictsLeastFrequent(t *testing.T) {
+	var evicted []any
+	c := NewCache[string, int](Config{
+		MaxItems: 3,
+		Policy:   PolicyLFU,
+		OnEvict:  func(k, _ any, _ EvictReason) { evicted = append(evicted, k) },
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.Set("c", 3, -1)
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	c.Set("d", 4, -1) // b has the lowest frequency
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected LFU to evict 'b', got %v", evicted)
+	}
+}
+
+type countingPolicy struct {
+	lruPolicy[string]
+	adds int
+}
+
+func (p *countingPolicy) Add(key string) {
+	p.adds++
+	p.lruPolicy.Add(key)
+}
+
+func TestCustomPolicy(t *testing.T) {
+	var built []*countingPolicy
+	c := NewCacheWith(CacheOptions[string, int]{
+		Config: Config{MaxItems: 2},
+		NewPolicy: func(capacity int) EvictionPolicy[string] {
+			if capacity != 2 {
+				t.Fatalf("expected capacity 2, got %d", capacity)
+			}
+			p := &countingPolicy{lruPolicy: *newLRUPolicy[string]()}
+			built = append(built, p)
+			return p
+		},
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.Set("c", 3, -1)
+	if len(built) != 1 || built[0].adds != 3 || c.Len() != 2 {
+		t.Fatalf("expected custom policy to drive eviction")
+	}
+}
+
+// traceReplay runs a request trace through a cache built with kind and
+// returns the hit ratio, filling misses the way a read-through cache would.
+func traceReplay(kind PolicyKind, capacity int, trace []int) float64 {
+	c := NewCache[int, int](Config{MaxItems: capacity, Policy: kind})
+	hits := 0
+	for _, k := range trace {
+		if _, ok := c.Get(k); ok {
+			hits++
+		} else {
+			c.Set(k, k, -1)
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// zipfScanTrace mixes a skewed hot set with periodic one-off scans, the
+// access pattern where recency-only policies do poorly.
+func zipfScanTrace(n int, seed int64) []int {
+	r := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(r, 1.1, 1, 5000)
+	trace := make([]int, 0, n)
+	scanKey := 1_000_000
+	for len(trace) < n {
+		for i := 0; i < 2000 && len(trace) < n; i++ {
+			trace = append(trace, int(zipf.Uint64()))
+		}
+		for i := 0; i < 600 && len(trace) < n; i++ {
+			trace = append(trace, scanKey)
+			scanKey++
+		}
+	}
+	return trace
+}
+
+func TestPolicies_TraceReplayHitRatios(t *testing.T) {
+	trace := zipfScanTrace(50_000, 7)
+	ratios := map[PolicyKind]float64{}
+	for _, kind := range allPolicies {
+		ratios[kind] = traceReplay(kind, 500, trace)
+		t.Logf("%-8s hit ratio %.3f", kind, ratios[kind])
+	}
+	for _, kind := range []PolicyKind{PolicyLFU, PolicyARC, PolicyS3FIFO, PolicyTinyLFU} {
+		if ratios[kind] <= ratios[PolicyLRU] {
+			t.Errorf("expected %s to beat LRU on a scan-heavy trace: %.3f <= %.3f", kind, ratios[kind], ratios[PolicyLRU])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package polyfill
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrSnapshotVersion is returned by Restore for streams written by an
+// incompatible version of Snapshot.
+var ErrSnapshotVersion = errors.New("cache: unsupported snapshot version")
+
+// snapshotVersion is bumped whenever the record layout changes.
+const snapshotVersion = 1
+
+// SnapshotCodec turns snapshot records into bytes and back. Both
+// encoding/gob and encoding/json encoders already have the required shape.
+type SnapshotCodec interface {
+	NewEncoder(w io.Writer) interface{ Encode(any) error }
+	NewDecoder(r io.Reader) interface{ Decode(any) error }
+}
+
+var (
+	// GobSnapshot encodes snapshots with encoding/gob (the default).
+	GobSnapshot SnapshotCodec = gobSnapshot{}
+	// JSONSnapshot encodes snapshots as a stream of JSON objects.
+	JSONSnapshot SnapshotCodec = jsonSnapshot{}
+)
+
+type gobSnapshot struct{}
+
+func (gobSnapshot) NewEncoder(w io.Writer) interface{ Encode(any) error } { return gob.NewEncoder(w) }
+func (gobSnapshot) NewDecoder(r io.Reader) interface{ Decode(any) error } { return gob.NewDecoder(r) }
+
+type jsonSnapshot struct{}
+
+func (jsonSnapshot) NewEncoder(w io.Writer) interface{ Encode(any) error } { return json.NewEncoder(w) }
+func (jsonSnapshot) NewDecoder(r io.Reader) interface{ Decode(any) error } { return json.NewDecoder(r) }
+
+type snapshotHeader struct {
+	Version int
+}
+
+type snapshotEntry[K comparable, V any] struct {
+	Key          K
+	Value        V
+	ExpiresAt    time.Time // zero => no expiration
+	Cost         int64
+	Idle         time.Duration // sliding window, 0 => fixed expiration
+	MaxExpiresAt time.Time     // sliding cap, zero => none
+	Tags         []string
+}
+
+// Snapshot writes every live entry to w with its absolute expiration time,
+// least recently used first within each shard, so Restore rebuilds the
+// same recency order. Expired entries are left out.
+//
+// Example:
+//
+//	f, _ := os.Create("cache.snap")
+//	defer f.Close()
+//	err := cache.Snapshot(f)
+func (c *Cache[K, V]) Snapshot(w io.Writer) error {
+	enc := c.codec().NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+	for _, sh := range c.shards {
+		for _, rec := range sh.snapshot() {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Restore loads entries written by Snapshot, keeping their remaining TTLs
+// and skipping those that expired in the meantime. Existing keys are
+// overwritten and capacity limits apply as usual. It returns the number of
+// entries stored.
+func (c *Cache[K, V]) Restore(r io.Reader) (int, error) {
+	dec := c.codec().NewDecoder(r)
+	var hdr snapshotHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return 0, err
+	}
+	if hdr.Version != snapshotVersion {
+		return 0, fmt.Errorf("%w: %d", ErrSnapshotVersion, hdr.Version)
+	}
+	n := 0
+	for {
+		var rec snapshotEntry[K, V]
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		en := &entry[K, V]{
+			key:          rec.Key,
+			val:          rec.Value,
+			expiresAt:    rec.ExpiresAt,
+			writtenAt:    c.clock.Now(),
+			cost:         rec.Cost,
+			idle:         rec.Idle,
+			maxExpiresAt: rec.MaxExpiresAt,
+			tags:         rec.Tags,
+		}
+		if c.isExpired(en) {
+			continue
+		}
+		c.store(rec.Key, en, false)
+		n++
+	}
+}
+
+// SnapshotFile writes a snapshot to path atomically: the data goes to a
+// temporary file in the same directory which then replaces path, so a
+// crash never leaves a truncated snapshot behind.
+func (c *Cache[K, V]) SnapshotFile(path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	bw := bufio.NewWriter(tmp)
+	if err = c.Snapshot(bw); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreFile restores a snapshot written by SnapshotFile. A missing file
+// returns an error satisfying errors.Is(err, fs.ErrNotExist).
+//
+// Example:
+//
+//	c := NewCache[string, User](Config{SnapshotPath: "users.snap", SnapshotInterval: time.Minute})
+//	if _, err := c.RestoreFile("users.snap"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+//		log.Printf("warm start failed: %v", err)
+//	}
+//	defer c.Close() // writes a final snapshot
+func (c *Cache[K, V]) RestoreFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return c.Restore(bufio.NewReader(f))
+}
+
+// -------- internals --------
+
+func (c *Cache[K, V]) codec() SnapshotCodec {
+	if c.cfg.SnapshotCodec != nil {
+		return c.cfg.SnapshotCodec
+	}
+	return GobSnapshot
+}
+
+// autoSnapshot writes SnapshotPath, reporting failures to OnSnapshotError.
+func (c *Cache[K, V]) autoSnapshot() {
+	if err := c.SnapshotFile(c.cfg.SnapshotPath); err != nil && c.cfg.OnSnapshotError != nil {
+		c.cfg.OnSnapshotError(err)
+	}
+}
+
+// snapshot copies the shard's live entries, coldest first when the
+// policy tracks recency.
+func (sh *cacheShard[K, V]) snapshot() []snapshotEntry[K, V] {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	out := make([]snapshotEntry[K, V], 0, len(sh.items))
+	add := func(k K, en *entry[K, V]) {
+		if !sh.c.isExpired(en) {
+			out = append(out, snapshotEntry[K, V]{
+				Key:          k,
+				Value:        en.val,
+				ExpiresAt:    en.expiresAt,
+				Cost:         en.cost,
+				Idle:         en.idle,
+				MaxExpiresAt: en.maxExpiresAt,
+				Tags:         en.tags,
+			})
+		}
+	}
+	if p, ok := sh.policy.(interface{ order() []K }); ok {
+		for _, k := range p.order() {
+			if en, ok := sh.items[k]; ok {
+				add(k, en)
+			}
+		}
+		return out
+	}
+	for k, en := range sh.items {
+		add(k, en)
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package polyfill
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestSnapshot_RoundTripKeepsTTLAndOrder(t *testing.T) {
+	for name, codec := range map[string]SnapshotCodec{"gob": GobSnapshot, "json": JSONSnapshot} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			src := NewCache[string, int](Config{MaxItems: 3, SnapshotCodec: codec, Clock: fakeclock.New(start)})
+			src.Set("a", 1, 10*time.Second)
+			src.Set("b", 2, -1)
+			src.Set("c", 3, time.Second)
+			src.Get("a") // recency, oldest first: b, c, a
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatalf("snapshot: %v", err)
+			}
+
+			var evicted []any
+			dst := NewCache[string, int](Config{
+				MaxItems:      3,
+				SnapshotCodec: codec,
+				OnEvict:       func(k, _ any, _ EvictReason) { evicted = append(evicted, k) },
+				Clock:         fakeclock.New(start.Add(2 * time.Second)),
+			})
+			n, err := dst.Restore(&buf)
+			if err != nil || n != 2 {
+				t.Fatalf("expected 2 restored entries, got %d (%v)", n, err)
+			}
+			if dst.Has("c") {
+				t.Fatalf("expired entry should not be restored")
+			}
+			if got := dst.shards[0].items["a"].expiresAt; !got.Equal(start.Add(10 * time.Second)) {
+				t.Fatalf("expected remaining TTL preserved, expires at %v", got)
+			}
+			if dst.shards[0].items["b"].expiresAt != (time.Time{}) {
+				t.Fatalf("expected 'b' to stay immortal")
+			}
+
+			dst.Set("d", 4, -1)
+			dst.Set("e", 5, -1) // over capacity: b is the least recent
+			if len(evicted) != 1 || evicted[0] != "b" {
+				t.Fatalf("expected recency order preserved (evict 'b'), got %v", evicted)
+			}
+		})
+	}
+}
+
+func TestRestore_RejectsUnknownVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := JSONSnapshot.NewEncoder(&buf).Encode(snapshotHeader{Version: 99}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache[string, int](Config{SnapshotCodec: JSONSnapshot})
+	if _, err := c.Restore(&buf); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("expected ErrSnapshotVersion, got %v", err)
+	}
+}
+
+func TestSnapshotFile_PeriodicAndOnClose(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+	c := NewCache[string, int](Config{SnapshotPath: path, SnapshotInterval: time.Minute, Clock: clk})
+	c.Set("a", 1, -1)
+
+	clk.Advance(time.Minute) // snapshotter receives and writes
+	clk.Advance(time.Minute) // second tick only lands after the first write
+	warm := NewCache[string, int](Config{})
+	if n, err := warm.RestoreFile(path); err != nil || n != 1 {
+		t.Fatalf("expected periodic snapshot with 1 entry, got %d (%v)", n, err)
+	}
+
+	c.Set("b", 2, -1)
+	c.Close()
+	warm = NewCache[string, int](Config{})
+	if n, err := warm.RestoreFile(path); err != nil || n != 2 {
+		t.Fatalf("expected final snapshot with 2 entries, got %d (%v)", n, err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected temp files to be renamed away, got %v", files)
+	}
+}
+
+func TestRestoreFile_Missing(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	if _, err := c.RestoreFile(filepath.Join(t.TempDir(), "nope")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package polyfill
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats is a point-in-time snapshot of cache activity counters.
+type CacheStats struct {
+	Hits          uint64                 `json:"hits"`
+	Misses        uint64                 `json:"misses"`
+	Sets          uint64                 `json:"sets"`
+	LoadSuccesses uint64                 `json:"load_successes"`
+	LoadFailures  uint64                 `json:"load_failures"`
+	TotalLoadTime time.Duration          `json:"total_load_time_ns"`
+	Evictions     map[EvictReason]uint64 `json:"evictions"`
+}
+
+// HitRatio returns hits/(hits+misses), or 0 before any lookup.
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AverageLoadTime returns the mean supplier latency over all loads.
+func (s CacheStats) AverageLoadTime() time.Duration {
+	loads := s.LoadSuccesses + s.LoadFailures
+	if loads == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(loads)
+}
+
+// evictReasons fixes the order of per-reason counters and exported metrics.
+var evictReasons = [...]EvictReason{EvictExpired, EvictCapacity, EvictManual, EvictClear}
+
+// cacheCounters holds the live atomic counters behind CacheStats.
+type cacheCounters struct {
+	hits, misses, sets       atomic.Uint64
+	loadSuccess, loadFailure atomic.Uint64
+	loadNanos                atomic.Int64
+	evictions                [len(evictReasons)]atomic.Uint64
+}
+
+func (cc *cacheCounters) evicted(reason EvictReason) {
+	for i, r := range evictReasons {
+		if r == reason {
+			cc.evictions[i].Add(1)
+			return
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache[K, V]) Stats() CacheStats {
+	s := CacheStats{
+		Hits:          c.stats.hits.Load(),
+		Misses:        c.stats.misses.Load(),
+		Sets:          c.stats.sets.Load(),
+		LoadSuccesses: c.stats.loadSuccess.Load(),
+		LoadFailures:  c.stats.loadFailure.Load(),
+		TotalLoadTime: time.Duration(c.stats.loadNanos.Load()),
+		Evictions:     make(map[EvictReason]uint64, len(evictReasons)),
+	}
+	for i, r := range evictReasons {
+		s.Evictions[r] = c.stats.evictions[i].Load()
+	}
+	return s
+}
+
+// ResetStats zeroes every counter.
+func (c *Cache[K, V]) ResetStats() {
+	c.stats.hits.Store(0)
+	c.stats.misses.Store(0)
+	c.stats.sets.Store(0)
+	c.stats.loadSuccess.Store(0)
+	c.stats.loadFailure.Store(0)
+	c.stats.loadNanos.Store(0)
+	for i := range c.stats.evictions {
+		c.stats.evictions[i].Store(0)
+	}
+}
+
+// StatsVar returns a value satisfying expvar.Var that renders the live
+// stats as JSON, so the package itself does not need to import expvar.
+//
+// Example:
+//
+//	expvar.Publish("users_cache", cache.StatsVar())
+func (c *Cache[K, V]) StatsVar() interface{ String() string } {
+	return statsVar(c.Stats)
+}
+
+type statsVar func() CacheStats
+
+func (f statsVar) String() string {
+	b, err := json.Marshal(f())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// WritePrometheus writes the stats and current entry count in the
+// Prometheus text exposition format, every metric prefixed with name.
+//
+// Example:
+//
+//	http.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+//		cache.WritePrometheus(w, "users_cache")
+//	})
+func (c *Cache[K, V]) WritePrometheus(w io.Writer, name string) error {
+	s := c.Stats()
+	pw := &promWriter{w: w, name: name}
+	pw.metric("hits_total", "counter", "Number of lookups that found a live entry.", float64(s.Hits))
+	pw.metric("misses_total", "counter", "Number of lookups that found no live entry.", float64(s.Misses))
+	pw.metric("sets_total", "counter", "Number of entries written.", float64(s.Sets))
+	pw.header("loads_total", "counter", "Number of supplier loads by result.")
+	pw.sample("loads_total", `{result="success"}`, float64(s.LoadSuccesses))
+	pw.sample("loads_total", `{result="failure"}`, float64(s.LoadFailures))
+	pw.metric("load_duration_seconds_total", "counter", "Total time spent in supplier loads.", s.TotalLoadTime.Seconds())
+	pw.header("evictions_total", "counter", "Number of entries removed by reason.")
+	for _, r := range evictReasons {
+		pw.sample("evictions_total", fmt.Sprintf(`{reason=%q}`, r), float64(s.Evictions[r]))
+	}
+	pw.metric("entries", "gauge", "Number of live entries.", float64(c.Len()))
+	return pw.err
+}
+
+// promWriter writes Prometheus text lines, remembering the first error.
+type promWriter struct {
+	w    io.Writer
+	name string
+	err  error
+}
+
+func (p *promWriter) metric(suffix, typ, help string, v float64) {
+	p.header(suffix, typ, help)
+	p.sample(suffix, "", v)
+}
+
+func (p *promWriter) header(suffix, typ, help string) {
+	p.printf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", p.name, suffix, help, p.name, suffix, typ)
+}
+
+func (p *promWriter) sample(suffix, labels string, v float64) {
+	p.printf("%s_%s%s %g\n", p.name, suffix, labels, v)
+}
+
+func (p *promWriter) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
@@ -0,0 +1,98 @@
+package polyfill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestStats_Counters(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	c := NewCache[string, int](Config{MaxItems: 2, Clock: clk})
+
+	c.Set("a", 1, -1)
+	c.Get("a")
+	c.Get("missing")
+	c.GetOrSet("b", func() (int, time.Duration, error) {
+		clk.Advance(30 * time.Millisecond)
+		return 2, -1, nil
+	})
+	c.GetOrSet("b", func() (int, time.Duration, error) { return 0, 0, nil })
+	c.GetOrSet("x", func() (int, time.Duration, error) {
+		clk.Advance(10 * time.Millisecond)
+		return 0, 0, errors.New("boom")
+	})
+	c.Set("c", 3, -1) // evicts by capacity
+	c.Delete("c")
+
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 3 {
+		t.Fatalf("expected 2 hits / 3 misses, got %d / %d", s.Hits, s.Misses)
+	}
+	if s.Sets != 3 || s.LoadSuccesses != 1 || s.LoadFailures != 1 {
+		t.Fatalf("unexpected sets/loads: %+v", s)
+	}
+	if s.TotalLoadTime != 40*time.Millisecond || s.AverageLoadTime() != 20*time.Millisecond {
+		t.Fatalf("unexpected load time: total=%v avg=%v", s.TotalLoadTime, s.AverageLoadTime())
+	}
+	if s.Evictions[EvictCapacity] != 1 || s.Evictions[EvictManual] != 1 {
+		t.Fatalf("unexpected evictions: %v", s.Evictions)
+	}
+	if r := s.HitRatio(); r != 0.4 {
+		t.Fatalf("expected hit ratio 0.4, got %v", r)
+	}
+
+	c.ResetStats()
+	if s := c.Stats(); s.Hits != 0 || s.Sets != 0 || s.Evictions[EvictCapacity] != 0 {
+		t.Fatalf("expected counters reset, got %+v", s)
+	}
+}
+
+func TestStats_Expvar(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	c.Set("a", 1, -1)
+	c.Get("a")
+	// expvar names are process-global: keep repeated runs (-count) apart
+	name := fmt.Sprintf("polyfill_test_cache_%d", time.Now().UnixNano())
+	expvar.Publish(name, c.StatsVar())
+
+	var got CacheStats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatalf("expvar output is not JSON: %v", err)
+	}
+	if got.Hits != 1 || got.Sets != 1 {
+		t.Fatalf("unexpected expvar stats: %+v", got)
+	}
+}
+
+func TestStats_WritePrometheus(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	c.Set("a", 1, -1)
+	c.Get("a")
+	c.Get("b")
+	c.Clear()
+
+	var buf bytes.Buffer
+	if err := c.WritePrometheus(&buf, "app_cache"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE app_cache_hits_total counter\napp_cache_hits_total 1\n",
+		"app_cache_misses_total 1\n",
+		`app_cache_loads_total{result="success"} 0` + "\n",
+		`app_cache_evictions_total{reason="clear"} 1` + "\n",
+		"# TYPE app_cache_entries gauge\napp_cache_entries 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package polyfill
+
+import (
+	"slices"
+	"time"
+)
+
+// SetWithTags stores/replaces a value labelled with tags so related
+// entries can be dropped together with InvalidateTag. Writing the key
+// again without tags clears them. ttl semantics like Set.
+//
+// Example:
+//
+//	c.SetWithTags("profile:42", p, time.Minute, "user:42")
+//	c.SetWithTags("feed:42", f, time.Minute, "user:42")
+//	c.InvalidateTag("user:42") // drops both
+func (c *Cache[K, V]) SetWithTags(key K, val V, ttl time.Duration, tags ...string) {
+	en := c.newEntry(key, val, -1, ttl, c.cfg.ExpireAfterAccess)
+	en.tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	c.store(key, en, ttl == 0 && c.expiry != nil)
+}
+
+// InvalidateTag removes every entry carrying tag and returns how many live
+// ones were removed. OnEvict fires with EvictManual, or EvictExpired for
+// expired entries still kept for the stale windows, which are dropped too.
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	n := 0
+	for _, sh := range c.shards {
+		sh.lock()
+		for k := range sh.tags[tag] {
+			if sh.removeMatchLocked(k, sh.items[k]) {
+				n++
+			}
+		}
+		sh.unlock()
+	}
+	return n
+}
+
+// DeleteFunc removes every entry for which pred returns true and returns
+// how many live ones were removed. OnEvict fires like for InvalidateTag.
+// pred runs under the shard lock and must not call back into the cache.
+//
+// Example:
+//
+//	c.DeleteFunc(func(k string, _ User) bool { return strings.HasPrefix(k, "tenant:7:") })
+func (c *Cache[K, V]) DeleteFunc(pred func(K, V) bool) int {
+	n := 0
+	for _, sh := range c.shards {
+		sh.lock()
+		for k, en := range sh.items {
+			if pred(k, en.val) && sh.removeMatchLocked(k, en) {
+				n++
+			}
+		}
+		sh.unlock()
+	}
+	return n
+}
+
+// -------- internals --------
+
+// removeMatchLocked removes an entry selected by InvalidateTag/DeleteFunc,
+// reporting whether it was live.
+func (sh *cacheShard[K, V]) removeMatchLocked(key K, en *entry[K, V]) bool {
+	if sh.c.isExpired(en) {
+		sh.removeKeyLocked(key, en, EvictExpired)
+		return false
+	}
+	sh.removeKeyLocked(key, en, EvictManual)
+	return true
+}
+
+func (sh *cacheShard[K, V]) tagLocked(key K, en *entry[K, V]) {
+	if len(en.tags) == 0 {
+		return
+	}
+	if sh.tags == nil {
+		sh.tags = make(map[string]map[K]struct{})
+	}
+	for _, t := range en.tags {
+		keys := sh.tags[t]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			sh.tags[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (sh *cacheShard[K, V]) untagLocked(key K, en *entry[K, V]) {
+	for _, t := range en.tags {
+		delete(sh.tags[t], key)
+		if len(sh.tags[t]) == 0 {
+			delete(sh.tags, t)
+		}
+	}
+}
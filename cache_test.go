@@ -2,10 +2,18 @@
 package polyfill
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
 )
 
 func TestSetGet_NoTTL(t *testing.T) {
@@ -20,9 +28,9 @@ func TestSetGet_NoTTL(t *testing.T) {
 }
 
 func TestSetGet_WithDefaultTTL(t *testing.T) {
-	c := NewCache[string, int](Config{DefaultTTL: time.Second})
 	start := time.Now()
-	c.now = func() time.Time { return start }
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{DefaultTTL: time.Second, Clock: clk})
 
 	c.Set("k", 42, 0) // uses DefaultTTL=1s
 	if v, ok := c.Get("k"); !ok || v != 42 {
@@ -34,7 +42,7 @@ func TestSetGet_WithDefaultTTL(t *testing.T) {
 	}
 
 	// advance beyond expiry
-	c.now = func() time.Time { return start.Add(1100 * time.Millisecond) }
+	clk.Set(start.Add(1100 * time.Millisecond))
 	if _, ok := c.Get("k"); ok {
 		t.Fatalf("expected expired")
 	}
@@ -51,14 +59,14 @@ func TestAdd_Exists(t *testing.T) {
 }
 
 func TestAdd_ReplacesExpired(t *testing.T) {
-	c := NewCache[string, string](Config{})
 	start := time.Now()
-	c.now = func() time.Time { return start }
+	clk := fakeclock.New(start)
+	c := NewCache[string, string](Config{Clock: clk})
 
 	if err := c.Add("x", "old", 10*time.Millisecond); err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
-	c.now = func() time.Time { return start.Add(20 * time.Millisecond) }
+	clk.Set(start.Add(20 * time.Millisecond))
 
 	// now expired; Add should succeed
 	if err := c.Add("x", "new", -1); err != nil {
@@ -86,9 +94,9 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestGetOrSet(t *testing.T) {
-	c := NewCache[string, int](Config{DefaultTTL: time.Second})
 	start := time.Now()
-	c.now = func() time.Time { return start }
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{DefaultTTL: time.Second, Clock: clk})
 
 	builds := 0
 	supplier := func() (int, time.Duration, error) {
@@ -108,7 +116,7 @@ func TestGetOrSet(t *testing.T) {
 	}
 
 	// expire and ensure supplier runs again
-	c.now = func() time.Time { return start.Add(2 * time.Second) }
+	clk.Set(start.Add(2 * time.Second))
 	v, err = c.GetOrSet("k", supplier)
 	if err != nil || v != 7 {
 		t.Fatalf("unexpected after expire: v=%v err=%v", v, err)
@@ -119,21 +127,21 @@ func TestGetOrSet(t *testing.T) {
 }
 
 func TestTouch_TTL(t *testing.T) {
-	c := NewCache[string, string](Config{DefaultTTL: 100 * time.Millisecond})
 	base := time.Now()
-	c.now = func() time.Time { return base }
+	clk := fakeclock.New(base)
+	c := NewCache[string, string](Config{DefaultTTL: 100 * time.Millisecond, Clock: clk})
 
 	c.Set("a", "v", 0) // uses DefaultTTL
 	if ok := c.Touch("a", 200*time.Millisecond); !ok {
 		t.Fatalf("touch should succeed")
 	}
 	// still alive after 150ms
-	c.now = func() time.Time { return base.Add(150 * time.Millisecond) }
+	clk.Set(base.Add(150 * time.Millisecond))
 	if _, ok := c.Get("a"); !ok {
 		t.Fatalf("expected alive after touch")
 	}
 	// dead after 300ms
-	c.now = func() time.Time { return base.Add(300 * time.Millisecond) }
+	clk.Set(base.Add(300 * time.Millisecond))
 	if _, ok := c.Get("a"); ok {
 		t.Fatalf("expected expired after extended ttl")
 	}
@@ -151,16 +159,16 @@ func TestDelete(t *testing.T) {
 }
 
 func TestSweep(t *testing.T) {
-	c := NewCache[string, int](Config{})
 	start := time.Now()
-	c.now = func() time.Time { return start }
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{Clock: clk})
 
 	c.Set("a", 1, 10*time.Millisecond)
 	c.Set("b", 2, -1)
 	c.Set("c", 3, 10*time.Millisecond)
 
 	// advance past expiry
-	c.now = func() time.Time { return start.Add(20 * time.Millisecond) }
+	clk.Set(start.Add(20 * time.Millisecond))
 	n := c.Sweep()
 	if n != 2 {
 		t.Fatalf("expected 2 swept, got %d", n)
@@ -257,15 +265,15 @@ func TestHasAndKeys(t *testing.T) {
 }
 
 func TestExpirationOnAccessRemoves(t *testing.T) {
-	c := NewCache[string, int](Config{})
 	start := time.Now()
-	c.now = func() time.Time { return start }
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{Clock: clk})
 
 	c.Set("die", 1, 5*time.Millisecond)
 	c.Set("live", 2, -1)
 
 	// advance time; accessing "die" should auto-remove
-	c.now = func() time.Time { return start.Add(10 * time.Millisecond) }
+	clk.Set(start.Add(10 * time.Millisecond))
 	if _, ok := c.Get("die"); ok {
 		t.Fatalf("expected die to be expired")
 	}
@@ -275,17 +283,805 @@ func TestExpirationOnAccessRemoves(t *testing.T) {
 }
 
 func TestTouchToImmortal(t *testing.T) {
-	c := NewCache[string, int](Config{DefaultTTL: 10 * time.Millisecond})
 	base := time.Now()
-	c.now = func() time.Time { return base }
+	clk := fakeclock.New(base)
+	c := NewCache[string, int](Config{DefaultTTL: 10 * time.Millisecond, Clock: clk})
 	c.Set("k", 1, 0) // gets default ttl
 
 	if ok := c.Touch("k", -1); !ok {
 		t.Fatalf("touch should succeed")
 	}
 	// even far in the future, should still exist
-	c.now = func() time.Time { return base.Add(5 * time.Second) }
+	clk.Set(base.Add(5 * time.Second))
 	if _, ok := c.Get("k"); !ok {
 		t.Fatalf("expected immortal after touch -1")
 	}
 }
+
+// stopClock records whether a ticker it handed out was stopped.
+type stopClock struct {
+	*fakeclock.Clock
+	stopped bool
+}
+
+func (c *stopClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	tick, stop := c.Clock.NewTicker(d)
+	return tick, func() { c.stopped = true; stop() }
+}
+
+func TestJanitor_SweepsOnTick(t *testing.T) {
+	clk := &stopClock{Clock: fakeclock.New(time.Now())}
+	var expired []any
+	c := NewCache[string, int](Config{
+		CleanupInterval: time.Minute,
+		OnEvict: func(k, _ any, r EvictReason) {
+			if r == EvictExpired {
+				expired = append(expired, k)
+			}
+		},
+		Clock: clk,
+	})
+
+	c.Set("old", 1, 10*time.Millisecond)
+	c.Set("keep", 2, -1)
+
+	clk.Advance(time.Minute) // janitor receives the tick and sweeps
+	c.Close()                // waits for the sweep to finish
+
+	if len(expired) != 1 || expired[0] != "old" {
+		t.Fatalf("expected janitor to expire 'old', got %v", expired)
+	}
+	if !clk.stopped {
+		t.Fatalf("expected ticker to be stopped on Close")
+	}
+	c.Close() // idempotent
+	if !c.Has("keep") {
+		t.Fatalf("cache should remain usable after Close")
+	}
+}
+
+func TestClose_WithoutJanitor(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	c.Close()
+	c.Set("a", 1, -1)
+	if !c.Has("a") {
+		t.Fatalf("cache should remain usable after Close")
+	}
+}
+
+func TestGetOrSet_SharesInFlightLoad(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	release := make(chan struct{})
+	var builds atomic.Int32
+	supplier := func() (int, time.Duration, error) {
+		builds.Add(1)
+		<-release
+		return 9, -1, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.GetOrSet("k", supplier)
+			if err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			results[i] = v
+		}(i)
+	}
+	// every caller has missed while the load is blocked, so each one is
+	// (or is about to be) parked on the in-flight call
+	for c.stats.misses.Load() < uint64(len(results)) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := builds.Load(); n != 1 {
+		t.Fatalf("supplier should run once, ran %d", n)
+	}
+	for _, v := range results {
+		if v != 9 {
+			t.Fatalf("expected every caller to get 9, got %v", results)
+		}
+	}
+}
+
+func TestGetOrSet_SlowSupplierDoesNotBlockReaders(t *testing.T) {
+	c := NewCache[string, int](Config{MaxItems: 10})
+	c.Set("other", 1, -1)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go c.GetOrSet("slow", func() (int, time.Duration, error) {
+		close(started)
+		<-release
+		return 2, -1, nil
+	})
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Get("other")
+		c.Set("another", 3, -1)
+		c.Has("other")
+		c.GetOrSet("fast", func() (int, time.Duration, error) { return 4, -1, nil })
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("cache operations blocked by a slow supplier")
+	}
+	close(release)
+}
+
+func TestGetOrSet_ErrorNotCached(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	boom := errors.New("boom")
+	if _, err := c.GetOrSet("k", func() (int, time.Duration, error) { return 0, 0, boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if c.Has("k") {
+		t.Fatalf("failed load must not be stored")
+	}
+	if v, err := c.GetOrSet("k", func() (int, time.Duration, error) { return 5, 0, nil }); err != nil || v != 5 {
+		t.Fatalf("expected retry to load, got %v %v", v, err)
+	}
+}
+
+func TestGetOrSetCtx_WaiterCancels(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	release := make(chan struct{})
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		c.GetOrSet("k", func() (int, time.Duration, error) {
+			<-release
+			return 7, -1, nil
+		})
+	}()
+	// wait until the load is registered
+	for {
+		c.callMu.Lock()
+		_, inFlight := c.calls["k"]
+		c.callMu.Unlock()
+		if inFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetOrSetCtx(ctx, "k", func() (int, time.Duration, error) {
+		t.Errorf("waiter must not start a second load")
+		return 0, 0, nil
+	}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	close(release)
+	<-loaded
+	if v, ok := c.Get("k"); !ok || v != 7 {
+		t.Fatalf("expected load to complete, got %v %v", v, ok)
+	}
+}
+
+func TestGetOrSet_WriteDuringLoadWins(t *testing.T) {
+	for name, write := range map[string]func(c *Cache[string, int]){
+		"set":    func(c *Cache[string, int]) { c.Set("k", 2, -1) },
+		"delete": func(c *Cache[string, int]) { c.Delete("k") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache[string, int](Config{})
+			started, release := make(chan struct{}), make(chan struct{})
+			loaded := make(chan int)
+			go func() {
+				v, _ := c.GetOrSet("k", func() (int, time.Duration, error) {
+					close(started)
+					<-release
+					return 1, -1, nil
+				})
+				loaded <- v
+			}()
+			<-started
+			write(c)
+			close(release)
+			if v := <-loaded; v != 1 {
+				t.Fatalf("expected the loading caller to get its result, got %d", v)
+			}
+			v, ok := c.Get("k")
+			if (name == "delete") == ok || ok && v != 2 {
+				t.Fatalf("expected the write during the load to win, got %v %v", v, ok)
+			}
+		})
+	}
+}
+
+func TestGetOrSet_SupplierPanicReleasesKey(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	boom := func() (int, time.Duration, error) { panic("boom") }
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the inline caller to see the panic, got %v", r)
+			}
+		}()
+		c.GetOrSet("k", boom)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.GetOrSetCtx(ctx, "k", boom); !errors.Is(err, ErrSupplierPanic) {
+		t.Fatalf("expected ErrSupplierPanic, got %v", err)
+	}
+	if v, err := c.GetOrSetCtx(ctx, "k", func() (int, time.Duration, error) { return 3, -1, nil }); err != nil || v != 3 {
+		t.Fatalf("expected the key to load again after a panic, got %v %v", v, err)
+	}
+	if len(c.calls) != 0 {
+		t.Fatalf("expected no leftover calls, got %d", len(c.calls))
+	}
+}
+
+func TestSharded_BehavesAsOneCache(t *testing.T) {
+	var evicted []any
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[int, int](Config{
+		Shards: 4,
+		OnEvict: func(k, _ any, r EvictReason) {
+			if r == EvictExpired {
+				evicted = append(evicted, k)
+			}
+		},
+		Clock: clk,
+	})
+
+	for i := 0; i < 100; i++ {
+		ttl := time.Duration(-1)
+		if i%10 == 0 {
+			ttl = time.Second
+		}
+		c.Set(i, i*i, ttl)
+	}
+	if c.Len() != 100 || len(c.Keys()) != 100 {
+		t.Fatalf("expected 100 entries, got len=%d keys=%d", c.Len(), len(c.Keys()))
+	}
+	used := 0
+	for _, sh := range c.shards {
+		if sh.size > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Fatalf("expected keys spread over shards, %d used", used)
+	}
+	if v, ok := c.Get(7); !ok || v != 49 {
+		t.Fatalf("expected (49,true), got (%v,%v)", v, ok)
+	}
+
+	clk.Set(start.Add(2 * time.Second))
+	if n := c.Sweep(); n != 10 || len(evicted) != 10 {
+		t.Fatalf("expected 10 swept, got %d (%d callbacks)", n, len(evicted))
+	}
+	if c.Len() != 90 {
+		t.Fatalf("expected len=90 after sweep, got %d", c.Len())
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("expected empty after clear")
+	}
+}
+
+func TestSharded_LimitsAddUpExactly(t *testing.T) {
+	for _, shards := range []int{3, 16} {
+		c := NewCache[int, int](Config{Shards: shards, MaxItems: 10, MaxCost: 20})
+		items, cost := 0, int64(0)
+		for _, sh := range c.shards {
+			items += sh.maxItems
+			cost += sh.maxCost
+		}
+		if items != 10 || cost != 20 {
+			t.Fatalf("shards=%d: expected limits 10/20, got %d/%d", shards, items, cost)
+		}
+		for i := 0; i < 100; i++ {
+			c.Set(i, i, -1)
+		}
+		if n := c.Len(); n > 10 {
+			t.Fatalf("shards=%d: expected at most MaxItems entries, got %d", shards, n)
+		}
+	}
+}
+
+func TestSharded_PerShardCapacityAndHasher(t *testing.T) {
+	// route every key to shard 0 so the per-shard limit is observable
+	c := NewCacheWith(CacheOptions[string, int]{
+		Config: Config{Shards: 2, MaxItems: 4},
+		Hasher: func(string) uint64 { return 0 },
+	})
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprint(i), i, -1)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected shard capacity 2, got len=%d", c.Len())
+	}
+	if !c.Has("4") || !c.Has("3") || c.Has("0") {
+		t.Fatalf("expected the two most recent keys to survive, got %v", c.Keys())
+	}
+}
+
+func TestDefaultHasher_StableForEqualKeys(t *testing.T) {
+	type point struct{ X, Y int }
+	h := defaultHasher[point]()
+	if h(point{1, 2}) != h(point{1, 2}) {
+		t.Fatalf("equal keys must hash equally")
+	}
+	hs := defaultHasher[string]()
+	if hs("a") != hs("a") {
+		t.Fatalf("equal keys must hash equally")
+	}
+}
+
+func TestDefaultHasher_SignedZero(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	c := NewCache[float64, int](Config{Shards: 16})
+	c.Set(0.0, 1, -1)
+	if v, ok := c.Get(negZero); !ok || v != 1 {
+		t.Fatalf("expected -0 to find the +0 entry, got %v %v", v, ok)
+	}
+
+	type key struct {
+		Name string
+		At   [2]float64
+		Any  any
+	}
+	h := defaultHasher[key]()
+	if h(key{"a", [2]float64{0, 1}, 0.0}) != h(key{"a", [2]float64{negZero, 1}, negZero}) {
+		t.Fatalf("struct keys equal under == must hash equally")
+	}
+	if h(key{"a", [2]float64{0, 1}, nil}) == h(key{"b", [2]float64{0, 1}, nil}) {
+		t.Fatalf("expected different keys to (almost surely) hash differently")
+	}
+}
+
+func benchmarkCacheParallel(b *testing.B, shards int) {
+	c := NewCache[int, int](Config{Shards: shards, MaxItems: 1 << 16})
+	for i := 0; i < 1<<12; i++ {
+		c.Set(i, i, -1)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				c.Set(i&(1<<12-1), i, -1)
+			} else {
+				c.Get(i & (1<<12 - 1))
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheParallel_Unsharded(b *testing.B) { benchmarkCacheParallel(b, 1) }
+func BenchmarkCacheParallel_Sharded16(b *testing.B) { benchmarkCacheParallel(b, 16) }
+
+func TestMaxCost_EvictsUntilFits(t *testing.T) {
+	var evicted []any
+	c := NewCacheWith(CacheOptions[string, []byte]{
+		Config: Config{
+			MaxCost: 10,
+			OnEvict: func(k, _ any, r EvictReason) {
+				if r == EvictCapacity {
+					evicted = append(evicted, k)
+				}
+			},
+		},
+		Cost: func(_ string, v []byte) int64 { return int64(len(v)) },
+	})
+	c.Set("a", make([]byte, 4), -1)
+	c.Set("b", make([]byte, 4), -1)
+	c.Get("a")                      // b becomes LRU
+	c.Set("c", make([]byte, 5), -1) // 13 > 10: evict b
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected capacity-evict 'b', got %v", evicted)
+	}
+	c.SetWithCost("d", nil, 6, -1) // explicit cost overrides Cost func; evicts a then c
+	if len(evicted) != 3 || c.Len() != 1 || !c.Has("d") {
+		t.Fatalf("expected only 'd' to remain, evicted=%v keys=%v", evicted, c.Keys())
+	}
+}
+
+func TestMaxCost_OversizedEntryRejected(t *testing.T) {
+	var evicted []any
+	c := NewCache[string, int](Config{
+		MaxCost: 5,
+		OnEvict: func(k, _ any, r EvictReason) {
+			if r == EvictCapacity {
+				evicted = append(evicted, k)
+			}
+		},
+	})
+	c.Set("a", 1, -1)
+	c.Set("b", 2, -1)
+	c.SetWithCost("huge", 3, 100, -1)
+	if len(evicted) != 1 || evicted[0] != "huge" {
+		t.Fatalf("expected only the oversized entry to be evicted, got %v", evicted)
+	}
+	if !c.Has("a") || !c.Has("b") {
+		t.Fatalf("existing entries should survive an oversized insert")
+	}
+}
+
+func TestMaxCost_ShardedKeepsEntriesWithinBudget(t *testing.T) {
+	c := NewCacheWith(CacheOptions[string, int]{
+		Config: Config{MaxCost: 100, Shards: 16}, // shares of 6-7
+		Hasher: func(string) uint64 { return 0 },
+	})
+	c.SetWithCost("a", 1, 10, -1)
+	if !c.Has("a") {
+		t.Fatalf("expected an entry within MaxCost to be kept despite the shard share")
+	}
+	c.SetWithCost("b", 2, 10, -1)
+	if c.Has("a") || !c.Has("b") {
+		t.Fatalf("expected the shard to shrink back to one large entry, got %v", c.Keys())
+	}
+	c.SetWithCost("c", 3, 101, -1)
+	if c.Has("c") || !c.Has("b") {
+		t.Fatalf("expected only entries over the whole MaxCost to be rejected, got %v", c.Keys())
+	}
+}
+
+func TestMaxCost_UpdateRecomputesCost(t *testing.T) {
+	c := NewCacheWith(CacheOptions[string, string]{
+		Config: Config{MaxCost: 8},
+		Cost:   func(_ string, v string) int64 { return int64(len(v)) },
+	})
+	c.Set("a", "xx", -1)
+	c.Set("b", "yy", -1)
+	if err := c.Update("b", func(v *string) error { *v = "yyyyyyy"; return nil }); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if c.Has("a") || !c.Has("b") {
+		t.Fatalf("expected growing 'b' to evict 'a', keys=%v", c.Keys())
+	}
+}
+
+// waitNoLoads blocks until no GetOrSet load is in flight, so tests can move
+// the fake clock without racing a background refresh.
+func waitNoLoads(c *Cache[string, int]) {
+	for {
+		c.callMu.Lock()
+		n := len(c.calls)
+		c.callMu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefreshAfter_ServesCurrentAndReloadsOnce(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{DefaultTTL: 10 * time.Second, RefreshAfter: 5 * time.Second, Clock: clk})
+	c.Set("k", 1, 0)
+
+	clk.Set(start.Add(6 * time.Second))
+	release := make(chan struct{})
+	var loads atomic.Int32
+	supplier := func() (int, time.Duration, error) {
+		loads.Add(1)
+		<-release
+		return 2, 0, nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := c.GetOrSet("k", supplier); err != nil || v != 1 {
+			t.Fatalf("expected current value 1 while refreshing, got %v %v", v, err)
+		}
+	}
+	close(release)
+	waitNoLoads(c)
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected a single background reload, got %d", n)
+	}
+	if v, ok := c.Get("k"); !ok || v != 2 {
+		t.Fatalf("expected refreshed value 2, got %v %v", v, ok)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{StaleWhileRevalidate: 5 * time.Second, Clock: clk})
+	c.Set("k", 1, time.Second)
+
+	clk.Set(start.Add(2 * time.Second))
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("Get must not return stale values")
+	}
+	if n := c.Sweep(); n != 0 {
+		t.Fatalf("stale entry should survive sweep inside its window, swept %d", n)
+	}
+	v, err := c.GetOrSet("k", func() (int, time.Duration, error) { return 2, time.Second, nil })
+	if err != nil || v != 1 {
+		t.Fatalf("expected stale value 1, got %v %v", v, err)
+	}
+	waitNoLoads(c)
+	if v, ok := c.Get("k"); !ok || v != 2 {
+		t.Fatalf("expected revalidated value 2, got %v %v", v, ok)
+	}
+
+	// past expiry plus the stale window the load is synchronous again
+	clk.Set(start.Add(20 * time.Second))
+	v, err = c.GetOrSet("k", func() (int, time.Duration, error) { return 3, time.Second, nil })
+	if err != nil || v != 3 {
+		t.Fatalf("expected synchronous load of 3, got %v %v", v, err)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{StaleIfError: 5 * time.Second, Clock: clk})
+	c.Set("k", 1, time.Second)
+	boom := errors.New("boom")
+	failing := func() (int, time.Duration, error) { return 0, 0, boom }
+
+	clk.Set(start.Add(3 * time.Second))
+	if v, err := c.GetOrSet("k", failing); err != nil || v != 1 {
+		t.Fatalf("expected stale value on error, got %v %v", v, err)
+	}
+
+	clk.Set(start.Add(10 * time.Second))
+	if _, err := c.GetOrSet("k", failing); !errors.Is(err, boom) {
+		t.Fatalf("expected error past the stale window, got %v", err)
+	}
+	if c.Sweep() != 1 {
+		t.Fatalf("expected dead stale entry to be swept")
+	}
+}
+
+func TestExpireAfterAccess_SlidesOnReadWithinCap(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{ExpireAfterAccess: 10 * time.Second, Clock: clk})
+
+	c.Set("session", 1, 25*time.Second) // write TTL caps the lifetime
+	c.Set("idle", 2, -1)
+	for i := 0; i < 2; i++ {
+		clk.Advance(8 * time.Second)
+		if _, ok := c.Get("session"); !ok {
+			t.Fatalf("expected read at %v to keep the entry alive", clk.Now().Sub(start))
+		}
+	}
+	if c.Has("idle") {
+		t.Fatalf("expected unread entry to expire after 10s idle")
+	}
+	// 16s: last read slid expiry to 26s, but the cap is 25s
+	clk.Set(start.Add(24 * time.Second))
+	if _, ok := c.Get("session"); !ok {
+		t.Fatalf("expected entry alive before the cap")
+	}
+	clk.Set(start.Add(25*time.Second + time.Millisecond))
+	if c.Has("session") {
+		t.Fatalf("expected the write TTL to cap sliding expiration")
+	}
+}
+
+func TestSetSliding_PerEntry(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	c := NewCache[string, int](Config{Clock: clk})
+
+	c.SetSliding("s", 1, time.Second, -1)
+	c.Set("fixed", 2, 1500*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		clk.Advance(800 * time.Millisecond)
+		c.Get("fixed")
+		if _, ok := c.Get("s"); !ok {
+			t.Fatalf("expected sliding entry alive at step %d", i)
+		}
+	}
+	if c.Has("fixed") {
+		t.Fatalf("reads must not extend a fixed TTL")
+	}
+	clk.Advance(1001 * time.Millisecond)
+	if c.Has("s") {
+		t.Fatalf("expected sliding entry to expire once idle")
+	}
+
+	// Touch replaces the cap
+	c.SetSliding("t", 1, time.Minute, time.Second)
+	c.Touch("t", time.Hour)
+	clk.Advance(30 * time.Second)
+	if !c.Has("t") {
+		t.Fatalf("expected Touch to lift the cap")
+	}
+}
+
+type testToken struct {
+	id      string
+	expires time.Time
+}
+
+// readExtends gives entries 10s, and every read resets them to 5s.
+type readExtends struct{ updates int }
+
+func (readExtends) AfterCreate(string, int, time.Time) time.Duration { return 10 * time.Second }
+func (r *readExtends) AfterUpdate(_ string, _ int, _ time.Time, current time.Duration) time.Duration {
+	r.updates++
+	return current
+}
+func (readExtends) AfterRead(string, int, time.Time, time.Duration) time.Duration {
+	return 5 * time.Second
+}
+
+func TestExpiry_DerivesTTLFromValue(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCacheWith(CacheOptions[string, testToken]{
+		Config: Config{Clock: clk},
+		Expiry: ExpireAt(func(_ string, tok testToken) time.Time { return tok.expires }),
+	})
+
+	c.Set("a", testToken{"a", start.Add(3 * time.Second)}, 0)
+	c.Set("b", testToken{"b", time.Time{}}, 0)
+	c.Set("c", testToken{"c", start.Add(3 * time.Second)}, time.Hour) // explicit ttl wins
+	clk.Set(start.Add(2 * time.Second))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected token alive before its own expiry")
+	}
+	clk.Set(start.Add(4 * time.Second))
+	if c.Has("a") || !c.Has("b") || !c.Has("c") {
+		t.Fatalf("unexpected liveness: a=%v b=%v c=%v", c.Has("a"), c.Has("b"), c.Has("c"))
+	}
+}
+
+func TestExpiry_HooksOnCreateUpdateRead(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	exp := &readExtends{}
+	c := NewCacheWith(CacheOptions[string, int]{Config: Config{Clock: clk}, Expiry: exp})
+
+	c.Set("k", 1, 0)
+	c.Update("k", func(v *int) error { *v = 2; return nil })
+	c.Set("k", 3, 0)
+	if exp.updates != 2 {
+		t.Fatalf("expected AfterUpdate for Update and overwrite, got %d", exp.updates)
+	}
+	if rem := c.shards[0].items["k"].expiresAt.Sub(start); rem != 10*time.Second {
+		t.Fatalf("expected AfterUpdate to keep the create TTL, got %v", rem)
+	}
+	clk.Set(start.Add(8 * time.Second))
+	c.Get("k") // now expires at 13s
+	clk.Set(start.Add(12 * time.Second))
+	if !c.Has("k") {
+		t.Fatalf("expected AfterRead to extend expiry")
+	}
+	clk.Set(start.Add(14 * time.Second))
+	if c.Has("k") {
+		t.Fatalf("expected expiry 5s after the last read")
+	}
+}
+
+func TestSetUntil(t *testing.T) {
+	start := time.Now()
+	clk := fakeclock.New(start)
+	c := NewCache[string, int](Config{DefaultTTL: time.Hour, Clock: clk})
+
+	c.SetUntil("a", 1, start.Add(time.Second))
+	c.SetUntil("forever", 2, time.Time{})
+	clk.Set(start.Add(2 * time.Second))
+	if c.Has("a") {
+		t.Fatalf("expected entry to expire at its deadline")
+	}
+	clk.Set(start.Add(48 * time.Hour))
+	if !c.Has("forever") {
+		t.Fatalf("expected zero deadline to never expire")
+	}
+}
+
+func TestTags_InvalidateTag(t *testing.T) {
+	var manual []any
+	c := NewCache[string, int](Config{
+		Shards: 4,
+		OnEvict: func(k, _ any, r EvictReason) {
+			if r == EvictManual {
+				manual = append(manual, k)
+			}
+		},
+	})
+	c.SetWithTags("profile:1", 1, -1, "user:1")
+	c.SetWithTags("feed:1", 2, -1, "user:1", "feeds", "user:1")
+	c.SetWithTags("feed:2", 3, -1, "user:2", "feeds")
+	c.SetWithTags("retagged", 4, -1, "user:1")
+	c.Set("retagged", 4, -1) // rewriting without tags drops them
+
+	if n := c.InvalidateTag("user:1"); n != 2 {
+		t.Fatalf("expected 2 entries removed, got %d", n)
+	}
+	if c.Has("profile:1") || c.Has("feed:1") || !c.Has("feed:2") || !c.Has("retagged") {
+		t.Fatalf("unexpected survivors: %v", c.Keys())
+	}
+	if len(manual) != 2 {
+		t.Fatalf("expected OnEvict with EvictManual twice, got %v", manual)
+	}
+	if n := c.InvalidateTag("feeds"); n != 1 {
+		t.Fatalf("expected removed entries to leave the tag index, got %d", n)
+	}
+	if n := c.InvalidateTag("user:1"); n != 0 {
+		t.Fatalf("expected nothing left under the tag, got %d", n)
+	}
+}
+
+func TestDeleteFunc_ByPrefix(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	for _, k := range []string{"tenant:1:a", "tenant:1:b", "tenant:2:a"} {
+		c.Set(k, 1, -1)
+	}
+	n := c.DeleteFunc(func(k string, _ int) bool { return strings.HasPrefix(k, "tenant:1:") })
+	if n != 2 || c.Len() != 1 || !c.Has("tenant:2:a") {
+		t.Fatalf("expected prefix delete of 2 entries, got n=%d keys=%v", n, c.Keys())
+	}
+}
+
+func TestTags_ExpiredEntriesNotCounted(t *testing.T) {
+	reasons := map[string]EvictReason{}
+	clk := fakeclock.New(time.Now())
+	c := NewCache[string, int](Config{
+		StaleWhileRevalidate: time.Minute,
+		OnEvict:              func(k, _ any, r EvictReason) { reasons[k.(string)] = r },
+		Clock:                clk,
+	})
+	for _, k := range []string{"live", "stale"} {
+		c.SetWithTags(k, 1, -1, "t")
+		c.Set("f:"+k, 1, -1)
+	}
+	c.SetWithTags("stale", 1, time.Second, "t")
+	c.Set("f:stale", 1, time.Second)
+	clk.Advance(2 * time.Second)
+
+	if n := c.InvalidateTag("t"); n != 1 {
+		t.Fatalf("expected only the live entry counted, got %d", n)
+	}
+	if n := c.DeleteFunc(func(k string, _ int) bool { return strings.HasPrefix(k, "f:") }); n != 1 {
+		t.Fatalf("expected only the live entry counted, got %d", n)
+	}
+	want := map[string]EvictReason{"live": EvictManual, "stale": EvictExpired, "f:live": EvictManual, "f:stale": EvictExpired}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Fatalf("expected stale entries dropped as expired, got %v", reasons)
+	}
+}
+
+func TestClock_FakeClockDrivesTTLAndJanitor(t *testing.T) {
+	clk := fakeclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var expired []any
+	c := NewCache[string, int](Config{
+		Clock:           clk,
+		CleanupInterval: time.Minute,
+		OnEvict: func(k, _ any, r EvictReason) {
+			if r == EvictExpired {
+				expired = append(expired, k)
+			}
+		},
+	})
+	c.Set("a", 1, 30*time.Second)
+	c.Set("b", 2, 5*time.Minute)
+	clk.Advance(10 * time.Second)
+	if ttl, ok := c.TTL("a"); !ok || ttl != 20*time.Second {
+		t.Fatalf("expected TTL to follow the fake clock, got %v %v", ttl, ok)
+	}
+	c.Touch("b", 30*time.Second)
+
+	clk.Advance(time.Minute) // janitor tick
+	c.Close()                // waits for the sweep
+	if len(expired) != 2 {
+		t.Fatalf("expected the janitor to expire both entries, got %v", expired)
+	}
+}
@@ -0,0 +1,75 @@
+package polyfill
+
+import "time"
+
+// Every write gives the entry a new version drawn from a per-cache counter,
+// so versions only ever increase and are never reused for a key, even
+// after it is deleted and stored again. Version 0 never matches an entry.
+
+// GetWithVersion is like Get but also returns the entry's version, for use
+// with CompareAndSwap and DeleteIfVersion.
+func (c *Cache[K, V]) GetWithVersion(key K) (V, uint64, bool) {
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	v, ok := sh.getLocked(key)
+	if !ok {
+		return v, 0, false
+	}
+	return v, sh.items[key].version, true
+}
+
+// CompareAndSwap replaces key's value only if its version is still
+// oldVersion, keeping its expiration and tags like Update. It returns the
+// new version, or ok=false if the key is missing or was changed meanwhile,
+// or if the new value's cost got the entry evicted.
+//
+// Example:
+//
+//	for {
+//		n, ver, _ := counters.GetWithVersion("hits")
+//		if _, ok := counters.CompareAndSwap("hits", ver, n+1); ok {
+//			break
+//		}
+//	}
+func (c *Cache[K, V]) CompareAndSwap(key K, oldVersion uint64, newVal V) (uint64, bool) {
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	en, ok := sh.items[key]
+	if sh.expiredLocked(key) || !ok || en.version != oldVersion {
+		return 0, false
+	}
+	en.val = newVal
+	sh.changedLocked(key, en)
+	if sh.items[key] != en {
+		return 0, false // evicted for its new cost
+	}
+	return en.version, true
+}
+
+// SetIfAbsent stores val only if key has no live entry, returning the new
+// entry's version and whether it was stored. ttl semantics like Set.
+func (c *Cache[K, V]) SetIfAbsent(key K, val V, ttl time.Duration) (uint64, bool) {
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	en, ok := sh.addLocked(key, val, ttl)
+	if !ok {
+		return 0, false
+	}
+	return en.version, true
+}
+
+// DeleteIfVersion removes key only if its version is still version.
+func (c *Cache[K, V]) DeleteIfVersion(key K, version uint64) bool {
+	sh := c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	en, ok := sh.items[key]
+	if sh.expiredLocked(key) || !ok || en.version != version {
+		return false
+	}
+	sh.removeKeyLocked(key, en, EvictManual)
+	return true
+}
@@ -0,0 +1,114 @@
+package polyfill
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestVersions_IncreaseOnEveryWrite(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	c.Set("a", 1, -1)
+	_, v1, _ := c.GetWithVersion("a")
+	c.Set("a", 2, -1)
+	_, v2, _ := c.GetWithVersion("a")
+	c.Update("a", func(v *int) error { *v++; return nil })
+	_, v3, _ := c.GetWithVersion("a")
+	c.Touch("a", time.Hour)
+	_, v4, _ := c.GetWithVersion("a")
+	if !(v1 > 0 && v2 > v1 && v3 > v2 && v4 == v3) {
+		t.Fatalf("expected increasing versions on writes only, got %d %d %d %d", v1, v2, v3, v4)
+	}
+	c.Delete("a")
+	c.Set("a", 1, -1)
+	if _, v5, _ := c.GetWithVersion("a"); v5 <= v3 {
+		t.Fatalf("expected versions never reused after delete, got %d", v5)
+	}
+	if _, v, ok := c.GetWithVersion("missing"); ok || v != 0 {
+		t.Fatalf("expected no version for missing key")
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	start := time.Now()
+	c := NewCache[string, int](Config{Clock: fakeclock.New(start)})
+	c.Set("a", 1, time.Minute)
+	_, ver, _ := c.GetWithVersion("a")
+
+	nv, ok := c.CompareAndSwap("a", ver, 2)
+	if !ok || nv <= ver {
+		t.Fatalf("expected swap to succeed with a new version, got %d %v", nv, ok)
+	}
+	if _, ok := c.CompareAndSwap("a", ver, 3); ok {
+		t.Fatalf("expected stale version to be rejected")
+	}
+	if v, _ := c.Get("a"); v != 2 {
+		t.Fatalf("expected 2, got %d", v)
+	}
+	if exp := c.shards[0].items["a"].expiresAt; !exp.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected CAS to keep the expiration, got %v", exp)
+	}
+	if _, ok := c.CompareAndSwap("missing", 0, 1); ok {
+		t.Fatalf("expected CAS on a missing key to fail")
+	}
+}
+
+func TestCompareAndSwap_EvictedByNewCost(t *testing.T) {
+	c := NewCacheWith(CacheOptions[string, int]{
+		Config: Config{MaxCost: 10},
+		Cost:   func(_ string, v int) int64 { return int64(v) },
+	})
+	c.Set("a", 1, -1)
+	_, ver, _ := c.GetWithVersion("a")
+	if _, ok := c.CompareAndSwap("a", ver, 11); ok {
+		t.Fatalf("expected a swap whose value was evicted to report failure")
+	}
+	if c.Has("a") {
+		t.Fatalf("expected the oversized value evicted")
+	}
+}
+
+func TestCompareAndSwap_ConcurrentIncrements(t *testing.T) {
+	c := NewCache[string, int](Config{Shards: 4})
+	c.Set("n", 0, -1)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for {
+					n, ver, _ := c.GetWithVersion("n")
+					if _, ok := c.CompareAndSwap("n", ver, n+1); ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := c.Get("n"); n != 800 {
+		t.Fatalf("expected no lost updates, got %d", n)
+	}
+}
+
+func TestSetIfAbsentAndDeleteIfVersion(t *testing.T) {
+	c := NewCache[string, int](Config{})
+	ver, ok := c.SetIfAbsent("a", 1, -1)
+	if !ok || ver == 0 {
+		t.Fatalf("expected first SetIfAbsent to store")
+	}
+	if _, ok := c.SetIfAbsent("a", 2, -1); ok {
+		t.Fatalf("expected SetIfAbsent to keep the existing entry")
+	}
+	c.Set("a", 3, -1)
+	if c.DeleteIfVersion("a", ver) {
+		t.Fatalf("expected delete with an outdated version to fail")
+	}
+	_, cur, _ := c.GetWithVersion("a")
+	if !c.DeleteIfVersion("a", cur) || c.Has("a") {
+		t.Fatalf("expected delete with the current version to succeed")
+	}
+}
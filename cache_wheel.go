@@ -0,0 +1,171 @@
+package polyfill
+
+import "time"
+
+// With Config.ExpirationResolution set, every shard keeps its entries'
+// deadlines in a hierarchical timing wheel: 5 levels of 64 slots, level L
+// covering 64^L ticks per slot. Scheduling and cancelling are O(1); each
+// tick pops one level-0 slot and, on level boundaries, cascades one slot
+// of the coarser levels down, so the work per expiration is O(1) amortized
+// no matter how many entries are live. Deadlines beyond the wheel's span
+// (~34 years at 1s) are parked at its far edge and rescheduled when they
+// come around.
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelLevels = 5
+	wheelSpan   = 1 << (wheelBits * wheelLevels)
+)
+
+// timerNode links an entry into a wheel slot; the zero value is unlinked.
+type timerNode[K comparable, V any] struct {
+	prev, next *timerNode[K, V]
+	en         *entry[K, V]
+	at         int64 // tick the entry is due
+}
+
+type timerWheel[K comparable, V any] struct {
+	tick  int64 // ns per tick
+	base  int64 // unix ns of tick 0
+	cur   int64 // last tick processed
+	count int
+	slots [wheelLevels][wheelSlots]timerNode[K, V] // list sentinels
+}
+
+// expireDue removes entries whose deadline (plus the stale grace) has
+// passed and reschedules the ones that were extended meanwhile.
+func (c *Cache[K, V]) expireDue() {
+	for _, sh := range c.shards {
+		sh.lock()
+		for _, en := range sh.wheel.advance(c.clock.Now()) {
+			switch {
+			case sh.items[en.key] != en:
+			case c.isDead(en):
+				sh.removeKeyLocked(en.key, en, EvictExpired)
+			default:
+				sh.scheduleLocked(en)
+			}
+		}
+		sh.unlock()
+	}
+}
+
+// -------- internals --------
+
+func (sh *cacheShard[K, V]) scheduleLocked(en *entry[K, V]) {
+	w := sh.wheel
+	if w == nil {
+		return
+	}
+	if en.expiresAt.IsZero() {
+		w.remove(&en.timer)
+		return
+	}
+	at := w.ticks(en.expiresAt.Add(sh.c.grace()))
+	if en.timer.next != nil && en.timer.at == at {
+		return
+	}
+	w.remove(&en.timer)
+	en.timer.en = en
+	en.timer.at = at
+	w.place(&en.timer, w.cur+1)
+	w.count++
+}
+
+func (sh *cacheShard[K, V]) unscheduleLocked(en *entry[K, V]) {
+	if sh.wheel != nil {
+		sh.wheel.remove(&en.timer)
+	}
+}
+
+func newTimerWheel[K comparable, V any](tick time.Duration, now time.Time) *timerWheel[K, V] {
+	w := &timerWheel[K, V]{tick: int64(tick), base: now.UnixNano()}
+	for l := range w.slots {
+		for s := range w.slots[l] {
+			head := &w.slots[l][s]
+			head.prev, head.next = head, head
+		}
+	}
+	return w
+}
+
+// ticks returns the first tick at or after t.
+func (w *timerWheel[K, V]) ticks(t time.Time) int64 {
+	d := t.UnixNano() - w.base
+	if d <= 0 {
+		return 0
+	}
+	return (d + w.tick - 1) / w.tick
+}
+
+// place links n into the slot for its tick, but no earlier than earliest.
+func (w *timerWheel[K, V]) place(n *timerNode[K, V], earliest int64) {
+	t := max(n.at, earliest)
+	delta := t - w.cur
+	if delta >= wheelSpan {
+		t, delta = w.cur+wheelSpan-1, wheelSpan-1
+	}
+	level := 0
+	for delta >= 1<<(wheelBits*(level+1)) {
+		level++
+	}
+	head := &w.slots[level][(t>>(wheelBits*level))&(wheelSlots-1)]
+	n.prev, n.next = head.prev, head
+	head.prev.next = n
+	head.prev = n
+}
+
+func (w *timerWheel[K, V]) remove(n *timerNode[K, V]) {
+	if n.next == nil {
+		return
+	}
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev, n.next = nil, nil
+	w.count--
+}
+
+// detach unlinks a whole slot and returns its first node (nil-terminated).
+func (w *timerWheel[K, V]) detach(head *timerNode[K, V]) *timerNode[K, V] {
+	if head.next == head {
+		return nil
+	}
+	first := head.next
+	head.prev.next = nil
+	head.prev, head.next = head, head
+	return first
+}
+
+// advance moves the wheel up to now and returns the unlinked entries that
+// came due on the way.
+func (w *timerWheel[K, V]) advance(now time.Time) []*entry[K, V] {
+	target := (now.UnixNano() - w.base) / w.tick
+	var due []*entry[K, V]
+	for w.cur < target {
+		if w.count == 0 {
+			w.cur = target
+			break
+		}
+		w.cur++
+		for l := wheelLevels - 1; l > 0; l-- {
+			shift := wheelBits * l
+			if w.cur&(1<<shift-1) != 0 {
+				continue
+			}
+			for n := w.detach(&w.slots[l][(w.cur>>shift)&(wheelSlots-1)]); n != nil; {
+				next := n.next
+				w.place(n, w.cur)
+				n = next
+			}
+		}
+		for n := w.detach(&w.slots[0][w.cur&(wheelSlots-1)]); n != nil; {
+			next := n.next
+			n.prev, n.next = nil, nil
+			w.count--
+			due = append(due, n.en)
+			n = next
+		}
+	}
+	return due
+}
@@ -0,0 +1,102 @@
+package polyfill
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestTimerWheel_PopsEachDeadlineOnItsTick(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newTimerWheel[int, int](time.Second, start)
+	r := rand.New(rand.NewSource(1))
+	want := map[*entry[int, int]]int64{}
+	for i := 0; i < 2000; i++ {
+		// spread over levels 0..3
+		at := 1 + r.Int63n(1<<(wheelBits*(1+i%4)))
+		en := &entry[int, int]{key: i}
+		en.timer.en, en.timer.at = en, at
+		w.place(&en.timer, w.cur+1)
+		w.count++
+		want[en] = at
+	}
+	for now := int64(1); len(want) > 0; now += 1 + r.Int63n(3000) {
+		for _, en := range w.advance(start.Add(time.Duration(now) * time.Second)) {
+			if at := want[en]; at > now || at <= now-3001 {
+				t.Fatalf("entry due at %d popped at %d", at, now)
+			}
+			delete(want, en)
+		}
+	}
+	if w.count != 0 {
+		t.Fatalf("expected an empty wheel, got %d", w.count)
+	}
+}
+
+func TestExpirationResolution_ExpiresWithoutAccess(t *testing.T) {
+	clk := fakeclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	expired := make(chan string, 8)
+	c := NewCacheWith(CacheOptions[string, int]{
+		Config: Config{Clock: clk, ExpirationResolution: time.Minute},
+		EvictListener: func(k string, _ int, r EvictReason) {
+			if r == EvictExpired {
+				expired <- k
+			}
+		},
+	})
+	defer c.Close()
+	next := func() string {
+		select {
+		case k := <-expired:
+			return k
+		case <-time.After(time.Second):
+			return ""
+		}
+	}
+
+	c.Set("a", 1, 90*time.Second)
+	c.Set("b", 2, 3*time.Minute)
+	c.Set("far", 3, 100*24*time.Hour) // parked on an upper level
+	c.Set("forever", 4, -1)
+
+	clk.Advance(2 * time.Minute)
+	if k := next(); k != "a" {
+		t.Fatalf("expected 'a' to expire on the next tick, got %q", k)
+	}
+	c.Touch("b", 5*time.Minute) // now due at 7m
+	clk.Advance(2 * time.Minute)
+	clk.Advance(2 * time.Minute)
+	c.Set("c", 5, time.Minute) // due at 7m as well
+	clk.Advance(2 * time.Minute)
+	got := map[string]bool{next(): true, next(): true}
+	if !got["b"] || !got["c"] {
+		t.Fatalf("expected the touched and new entries to expire together, got %v", got)
+	}
+
+	clk.Advance(100 * 24 * time.Hour)
+	if k := next(); k != "far" {
+		t.Fatalf("expected the far entry to cascade down and expire, got %q", k)
+	}
+	if c.Len() != 1 || !c.Has("forever") {
+		t.Fatalf("expected only the immortal entry to remain, got %v", c.Keys())
+	}
+}
+
+func TestTTLJitter_ShortensTTLs(t *testing.T) {
+	c := NewCache[string, int](Config{DefaultTTL: 10 * time.Second, TTLJitter: 0.2, Clock: fakeclock.New(time.Now())})
+	c.random = func() float64 { return 0.5 }
+	c.Set("a", 1, 0)
+	c.Set("b", 2, time.Minute)
+	c.Set("c", 3, -1)
+	if ttl, _ := c.TTL("a"); ttl != 9*time.Second {
+		t.Fatalf("expected jittered default TTL of 9s, got %v", ttl)
+	}
+	if ttl, _ := c.TTL("b"); ttl != 54*time.Second {
+		t.Fatalf("expected jittered TTL of 54s, got %v", ttl)
+	}
+	if ttl, ok := c.TTL("c"); ok {
+		t.Fatalf("expected immortal entries to stay immortal, got %v", ttl)
+	}
+}
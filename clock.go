@@ -0,0 +1,49 @@
+package polyfill
+
+import "time"
+
+// Clock is the time source of a Cache, FileStore or RateLimiter. Supplying
+// one through Config.Clock lets tests control expiry, refresh and the
+// background janitor; fakeclock.Clock satisfies it.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a channel ticking every d and a func stopping it.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+// Sleeper may be implemented by a Clock to control waiting as well: a
+// RateLimiter or RetryPolicy sleeps through its Clock when it is a Sleeper
+// and with time.Sleep otherwise. fakeclock.Clock's Sleep advances the fake
+// time.
+type Sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// systemClock is the default Clock, backed by package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// clockOr returns c, or the system clock when c is nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
+
+// sleepOn waits d through c if it is a Sleeper.
+func sleepOn(c Clock, d time.Duration) {
+	if s, ok := c.(Sleeper); ok {
+		s.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
@@ -0,0 +1,108 @@
+// Package fakeclock provides a manually advanced clock for testing code
+// that takes a polyfill.Clock, such as Cache and FileStore.
+package fakeclock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake time source. Time only moves when Advance or Set is
+// called. The zero value is not usable; create one with New.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[*ticker]struct{}
+}
+
+type ticker struct {
+	ch     chan time.Time
+	every  time.Duration
+	next   time.Time
+	stop   chan struct{}
+	closed bool
+}
+
+// New returns a Clock set to start.
+//
+// Example:
+//
+//	clk := fakeclock.New(time.Now())
+//	c := polyfill.NewCache[string, int](polyfill.Config{Clock: clk})
+//	c.Set("k", 1, time.Minute)
+//	clk.Advance(2 * time.Minute) // "k" is now expired
+func New(start time.Time) *Clock {
+	return &Clock{now: start, tickers: make(map[*ticker]struct{})}
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and fires every ticker that came
+// due, at most once each, like a time.Ticker with a slow reader. Ticks are
+// sent without holding the clock's lock and Advance waits until each one
+// is received, so a background loop has picked up its tick when Advance
+// returns.
+func (c *Clock) Advance(d time.Duration) {
+	c.move(func(now time.Time) time.Time { return now.Add(d) })
+}
+
+// Sleep advances the clock by d instead of blocking, so code that waits
+// through a polyfill.Sleeper, like RateLimiter, runs without delay.
+func (c *Clock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// Set moves the clock to t, firing due tickers like Advance.
+func (c *Clock) Set(t time.Time) {
+	c.move(func(time.Time) time.Time { return t })
+}
+
+func (c *Clock) move(to func(now time.Time) time.Time) {
+	c.mu.Lock()
+	t := to(c.now)
+	c.now = t
+	var due []*ticker
+	for tk := range c.tickers {
+		if !tk.next.After(t) {
+			due = append(due, tk)
+			for !tk.next.After(t) {
+				tk.next = tk.next.Add(tk.every)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, tk := range due {
+		select {
+		case tk.ch <- t:
+		case <-tk.stop:
+		}
+	}
+}
+
+// NewTicker returns a channel that receives the fake time every d of
+// advancement, and a func that stops it.
+func (c *Clock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		panic("fakeclock: non-positive interval for NewTicker")
+	}
+	tk := &ticker{ch: make(chan time.Time), every: d, stop: make(chan struct{})}
+	c.mu.Lock()
+	tk.next = c.now.Add(d)
+	c.tickers[tk] = struct{}{}
+	c.mu.Unlock()
+	return tk.ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !tk.closed {
+			tk.closed = true
+			delete(c.tickers, tk)
+			close(tk.stop)
+		}
+	}
+}
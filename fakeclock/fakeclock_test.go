@@ -0,0 +1,54 @@
+package fakeclock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock_AdvanceAndTickers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := New(start)
+	ch, stop := clk.NewTicker(time.Minute)
+
+	got := make(chan time.Time, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for tick := range ch {
+			got <- tick
+			if len(got) == 2 {
+				return
+			}
+		}
+	}()
+
+	clk.Advance(30 * time.Second) // not due yet
+	if len(got) != 0 {
+		t.Fatalf("ticker fired early")
+	}
+	clk.Advance(30 * time.Second)
+	clk.Advance(5 * time.Minute) // several periods collapse into one tick
+	<-done
+	if first := <-got; !first.Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected first tick %v", first)
+	}
+	if second := <-got; !second.Equal(start.Add(6 * time.Minute)) {
+		t.Fatalf("unexpected second tick %v", second)
+	}
+
+	stop()
+	stop()
+	clk.Advance(time.Hour) // must not block on a stopped ticker
+	if !clk.Now().Equal(start.Add(6*time.Minute + time.Hour)) {
+		t.Fatalf("unexpected time %v", clk.Now())
+	}
+}
+
+func TestClock_SleepAdvances(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := New(start)
+	clk.Sleep(3 * time.Second)
+	if got := clk.Now(); !got.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("expected Sleep to advance the clock, now %v", got)
+	}
+}
@@ -0,0 +1,414 @@
+package polyfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrStoreClosed is returned by FileStore operations after Close.
+var ErrStoreClosed = errors.New("cache: file store closed")
+
+// FileStoreOptions configures a FileStore.
+type FileStoreOptions struct {
+	// DefaultTTL is used by Set when ttl==0 (<=0 = no expiration).
+	DefaultTTL time.Duration
+
+	// SyncWrites fsyncs the log after every Set and Delete. Without it a
+	// crash can lose the most recent writes, but never corrupts older ones.
+	SyncWrites bool
+
+	// CompactInterval checks the log at this interval when >0 and rewrites
+	// it once more than CompactRatio of it is dead records.
+	CompactInterval time.Duration
+	CompactRatio    float64 // default 0.5
+
+	// OnError reports failures of background compaction.
+	OnError func(error)
+
+	// Clock supplies the time for TTLs and compaction (nil = system clock).
+	Clock Clock
+}
+
+// FileStore is a disk-backed Store: an append-only log of records with an
+// in-memory index of where each key's latest value lives. Opening a store
+// replays the log and drops a torn or corrupt tail left by a crash.
+// Overwritten, deleted and expired records are reclaimed by Compact.
+type FileStore[V any] struct {
+	mu      sync.RWMutex
+	path    string
+	f       *os.File
+	codec   Codec[V]
+	opts    FileStoreOptions
+	index   map[string]fileRecord
+	size    int64 // log length, the next write offset
+	garbage int64 // bytes of records no longer referenced
+	closed  bool
+	clock   Clock
+
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// fileRecord locates a key's latest value in the log.
+type fileRecord struct {
+	off     int64 // record start
+	length  int64 // whole record
+	valOff  int64
+	valLen  int
+	expires int64 // unix nanos, 0 => never
+}
+
+const (
+	opSet    byte = 1
+	opDelete byte = 2
+
+	// crc32 | op | expires | keyLen | valLen
+	recordHeaderSize = 4 + 1 + 8 + 4 + 4
+)
+
+// OpenFileStore opens or creates the log at path, recovering its index.
+//
+// Example:
+//
+//	store, err := OpenFileStore("/var/cache/app/renders.log", GobCodec[Render]{},
+//		FileStoreOptions{CompactInterval: time.Hour})
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	tc := NewTieredCache[string, Render](NewCache[string, Render](Config{MaxItems: 1000}), store, TieredOptions{})
+func OpenFileStore[V any](path string, codec Codec[V], opts FileStoreOptions) (*FileStore[V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CompactRatio <= 0 {
+		opts.CompactRatio = 0.5
+	}
+	s := &FileStore[V]{path: path, f: f, codec: codec, opts: opts, clock: clockOr(opts.Clock)}
+	if err := s.recover(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if opts.CompactInterval > 0 {
+		s.startCompactor(opts.CompactInterval)
+	}
+	return s, nil
+}
+
+// Get implements Store.
+func (s *FileStore[V]) Get(_ context.Context, key string) (V, bool, error) {
+	v, _, ok, err := s.get(key)
+	return v, ok, err
+}
+
+// GetWithTTL implements TTLStore.
+func (s *FileStore[V]) GetWithTTL(_ context.Context, key string) (V, time.Duration, bool, error) {
+	v, expires, ok, err := s.get(key)
+	if !ok || expires == 0 {
+		return v, -1, ok, err
+	}
+	return v, max(time.Unix(0, expires).Sub(s.clock.Now()), time.Nanosecond), true, nil
+}
+
+// Set implements Store. ttl semantics like Cache.Set.
+func (s *FileStore[V]) Set(_ context.Context, key string, val V, ttl time.Duration) error {
+	data, err := s.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if ttl == 0 {
+		ttl = s.opts.DefaultTTL
+	}
+	if ttl > 0 {
+		expires = s.clock.Now().Add(ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.appendLocked(opSet, key, data, expires)
+	if err != nil {
+		return err
+	}
+	if old, ok := s.index[key]; ok {
+		s.garbage += old.length
+	}
+	s.index[key] = rec
+	return nil
+}
+
+// Delete implements Store. Deleting a missing key is not an error.
+func (s *FileStore[V]) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStoreClosed
+	}
+	old, ok := s.index[key]
+	if !ok {
+		return nil
+	}
+	tomb, err := s.appendLocked(opDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	delete(s.index, key)
+	s.garbage += old.length + tomb.length
+	return nil
+}
+
+// Len returns the number of live keys.
+func (s *FileStore[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, rec := range s.index {
+		if !s.expired(rec) {
+			n++
+		}
+	}
+	return n
+}
+
+// Compact rewrites the log with only live records, atomically replacing
+// the old file.
+func (s *FileStore[V]) Compact() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".compact*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	bw := bufio.NewWriter(tmp)
+	index := make(map[string]fileRecord, len(s.index))
+	var off int64
+	for key, rec := range s.index {
+		if s.expired(rec) {
+			continue
+		}
+		raw := make([]byte, rec.length)
+		if _, err = s.f.ReadAt(raw, rec.off); err != nil {
+			return err
+		}
+		if _, err = bw.Write(raw); err != nil {
+			return err
+		}
+		index[key] = fileRecord{
+			off:     off,
+			length:  rec.length,
+			valOff:  off + (rec.valOff - rec.off),
+			valLen:  rec.valLen,
+			expires: rec.expires,
+		}
+		off += rec.length
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f, s.index, s.size, s.garbage = tmp, index, off, 0
+	return nil
+}
+
+// Close stops background compaction and closes the log file.
+func (s *FileStore[V]) Close() error {
+	s.stopOnce.Do(func() {
+		if s.quit != nil {
+			close(s.quit)
+			<-s.done
+		}
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.f.Close()
+}
+
+// -------- internals --------
+
+// get returns key's value and its expiry in unix nanoseconds (0 = never).
+func (s *FileStore[V]) get(key string) (V, int64, bool, error) {
+	var zero V
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return zero, 0, false, ErrStoreClosed
+	}
+	rec, ok := s.index[key]
+	if !ok || s.expired(rec) {
+		return zero, 0, false, nil
+	}
+	buf := make([]byte, rec.valLen)
+	if _, err := s.f.ReadAt(buf, rec.valOff); err != nil {
+		return zero, 0, false, err
+	}
+	v, err := s.codec.Unmarshal(buf)
+	if err != nil {
+		return zero, 0, false, err
+	}
+	return v, rec.expires, true, nil
+}
+
+func (s *FileStore[V]) expired(rec fileRecord) bool {
+	return rec.expires != 0 && s.clock.Now().UnixNano() > rec.expires
+}
+
+// appendLocked writes one record at the end of the log.
+func (s *FileStore[V]) appendLocked(op byte, key string, val []byte, expires int64) (fileRecord, error) {
+	if s.closed {
+		return fileRecord{}, ErrStoreClosed
+	}
+	buf := make([]byte, recordHeaderSize+len(key)+len(val))
+	buf[4] = op
+	binary.LittleEndian.PutUint64(buf[5:], uint64(expires))
+	binary.LittleEndian.PutUint32(buf[13:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(buf[17:], uint32(len(val)))
+	copy(buf[recordHeaderSize:], key)
+	copy(buf[recordHeaderSize+len(key):], val)
+	binary.LittleEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+
+	if _, err := s.f.WriteAt(buf, s.size); err != nil {
+		return fileRecord{}, err
+	}
+	if s.opts.SyncWrites {
+		if err := s.f.Sync(); err != nil {
+			return fileRecord{}, err
+		}
+	}
+	rec := fileRecord{
+		off:     s.size,
+		length:  int64(len(buf)),
+		valOff:  s.size + recordHeaderSize + int64(len(key)),
+		valLen:  len(val),
+		expires: expires,
+	}
+	s.size += rec.length
+	return rec, nil
+}
+
+// recover replays the log into the index, truncating it at the first
+// incomplete or corrupt record.
+func (s *FileStore[V]) recover() error {
+	st, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	total := st.Size()
+	s.index = make(map[string]fileRecord)
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, total))
+	var off int64
+	hdr := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			break // clean end or torn header
+		}
+		keyLen := int64(binary.LittleEndian.Uint32(hdr[13:]))
+		valLen := int64(binary.LittleEndian.Uint32(hdr[17:]))
+		length := recordHeaderSize + keyLen + valLen
+		if off+length > total {
+			break
+		}
+		body := make([]byte, keyLen+valLen)
+		if _, err := io.ReadFull(r, body); err != nil {
+			break
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(hdr[4:])
+		crc.Write(body)
+		if crc.Sum32() != binary.LittleEndian.Uint32(hdr) {
+			break
+		}
+
+		key := string(body[:keyLen])
+		if old, ok := s.index[key]; ok {
+			s.garbage += old.length
+		}
+		switch hdr[4] {
+		case opDelete:
+			delete(s.index, key)
+			s.garbage += length
+		default:
+			s.index[key] = fileRecord{
+				off:     off,
+				length:  length,
+				valOff:  off + recordHeaderSize + keyLen,
+				valLen:  int(valLen),
+				expires: int64(binary.LittleEndian.Uint64(hdr[5:])),
+			}
+		}
+		off += length
+	}
+	s.size = off
+	if off < total {
+		return s.f.Truncate(off)
+	}
+	return nil
+}
+
+func (s *FileStore[V]) startCompactor(interval time.Duration) {
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	tick, stop := s.clock.NewTicker(interval)
+	go func() {
+		defer close(s.done)
+		defer stop()
+		for {
+			select {
+			case <-tick:
+				if s.needsCompaction() {
+					if err := s.Compact(); err != nil && s.opts.OnError != nil {
+						s.opts.OnError(err)
+					}
+				}
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// needsCompaction reports whether dead records, overwritten, deleted or
+// expired, make up more than CompactRatio of the log.
+func (s *FileStore[V]) needsCompaction() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.size == 0 {
+		return false
+	}
+	garbage := s.garbage
+	for _, rec := range s.index {
+		if s.expired(rec) {
+			garbage += rec.length
+		}
+	}
+	return float64(garbage)/float64(s.size) > s.opts.CompactRatio
+}
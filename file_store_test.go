@@ -0,0 +1,213 @@
+package polyfill
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+type storedDoc struct {
+	Title string
+	Views int
+}
+
+func openTestStore(t *testing.T, path string, opts FileStoreOptions) *FileStore[storedDoc] {
+	t.Helper()
+	s, err := OpenFileStore[storedDoc](path, GobCodec[storedDoc]{}, opts)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFileStore_PersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "docs.log")
+	s := openTestStore(t, path, FileStoreOptions{SyncWrites: true})
+
+	s.Set(ctx, "a", storedDoc{"A", 1}, -1)
+	s.Set(ctx, "b", storedDoc{"B", 2}, -1)
+	s.Set(ctx, "a", storedDoc{"A", 3}, -1)
+	s.Delete(ctx, "b")
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, _, err := s.Get(ctx, "a"); err != ErrStoreClosed {
+		t.Fatalf("expected ErrStoreClosed, got %v", err)
+	}
+
+	s = openTestStore(t, path, FileStoreOptions{})
+	if v, ok, err := s.Get(ctx, "a"); err != nil || !ok || v != (storedDoc{"A", 3}) {
+		t.Fatalf("expected latest value after reopen, got %v %v %v", v, ok, err)
+	}
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Fatalf("expected delete to survive reopen")
+	}
+	if s.Len() != 1 {
+		t.Fatalf("expected 1 key, got %d", s.Len())
+	}
+}
+
+func TestFileStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	clk := fakeclock.New(time.Now())
+	s := openTestStore(t, filepath.Join(t.TempDir(), "ttl.log"), FileStoreOptions{DefaultTTL: time.Minute, Clock: clk})
+
+	s.Set(ctx, "default", storedDoc{}, 0)
+	s.Set(ctx, "short", storedDoc{}, time.Second)
+	s.Set(ctx, "forever", storedDoc{}, -1)
+	clk.Advance(2 * time.Second)
+	if _, ok, _ := s.Get(ctx, "short"); ok {
+		t.Fatalf("expected short entry expired")
+	}
+	clk.Advance(2 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "default"); ok {
+		t.Fatalf("expected DefaultTTL to apply")
+	}
+	if s.Len() != 1 {
+		t.Fatalf("expected only the immortal entry, got %d", s.Len())
+	}
+}
+
+func TestFileStore_RecoversFromTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "torn.log")
+	s := openTestStore(t, path, FileStoreOptions{})
+	s.Set(ctx, "ok", storedDoc{"kept", 1}, -1)
+	s.Set(ctx, "torn", storedDoc{"lost", 2}, -1)
+	good := s.index["torn"].off
+	s.Close()
+
+	// simulate a crash halfway through the last record, plus junk
+	st, _ := os.Stat(path)
+	if err := os.Truncate(path, st.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte("garbage"))
+	f.Close()
+
+	s = openTestStore(t, path, FileStoreOptions{})
+	if v, ok, _ := s.Get(ctx, "ok"); !ok || v.Title != "kept" {
+		t.Fatalf("expected intact record to survive")
+	}
+	if _, ok, _ := s.Get(ctx, "torn"); ok {
+		t.Fatalf("expected torn record to be dropped")
+	}
+	if st, _ := os.Stat(path); st.Size() != good {
+		t.Fatalf("expected log truncated to %d, got %d", good, st.Size())
+	}
+	s.Set(ctx, "after", storedDoc{"new", 3}, -1)
+	s.Close()
+	s = openTestStore(t, path, FileStoreOptions{})
+	if s.Len() != 2 {
+		t.Fatalf("expected writes after recovery to persist, got %d keys", s.Len())
+	}
+}
+
+func TestFileStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "compact.log")
+	clk := fakeclock.New(time.Now())
+	s := openTestStore(t, path, FileStoreOptions{Clock: clk})
+	for i := 0; i < 100; i++ {
+		s.Set(ctx, "hot", storedDoc{"hot", i}, -1)
+	}
+	s.Set(ctx, "gone", storedDoc{}, -1)
+	s.Delete(ctx, "gone")
+	s.Set(ctx, "expiring", storedDoc{}, time.Second)
+	before, _ := os.Stat(path)
+
+	clk.Advance(time.Minute)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/50 {
+		t.Fatalf("expected compaction to shrink the log: %d -> %d", before.Size(), after.Size())
+	}
+	if v, ok, _ := s.Get(ctx, "hot"); !ok || v.Views != 99 {
+		t.Fatalf("expected latest value after compaction, got %v", v)
+	}
+	s.Set(ctx, "post", storedDoc{"p", 1}, -1)
+	s.Close()
+
+	s = openTestStore(t, path, FileStoreOptions{})
+	if s.Len() != 2 {
+		t.Fatalf("expected compacted log to reopen with 2 keys, got %d", s.Len())
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Fatalf("expected no leftover temp files, got %v", files)
+	}
+}
+
+func TestFileStore_BackgroundCompaction(t *testing.T) {
+	ctx := context.Background()
+	clk := fakeclock.New(time.Now())
+	s := openTestStore(t, filepath.Join(t.TempDir(), "bg.log"), FileStoreOptions{CompactInterval: time.Minute, Clock: clk})
+	for i := 0; i < 10; i++ {
+		s.Set(ctx, "k", storedDoc{Views: i}, -1)
+	}
+	clk.Advance(time.Minute)
+	clk.Advance(time.Minute) // returns once the first compaction has finished
+	s.mu.RLock()
+	size, garbage, live := s.size, s.garbage, s.index["k"].length
+	s.mu.RUnlock()
+	if garbage != 0 || size != live {
+		t.Fatalf("expected background compaction, size=%d garbage=%d", size, garbage)
+	}
+}
+
+func TestFileStore_BackgroundCompactionReclaimsExpired(t *testing.T) {
+	ctx := context.Background()
+	clk := fakeclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := openTestStore(t, filepath.Join(t.TempDir(), "ttl-bg.log"), FileStoreOptions{
+		CompactInterval: time.Minute,
+		Clock:           clk,
+	})
+	for i := 0; i < 10; i++ {
+		s.Set(ctx, fmt.Sprint("short", i), storedDoc{Views: i}, 30*time.Second)
+	}
+	s.Set(ctx, "forever", storedDoc{Title: "F"}, -1)
+
+	clk.Advance(time.Minute)
+	clk.Advance(time.Minute) // returns once the first compaction has finished
+	s.mu.RLock()
+	size, keys, live := s.size, len(s.index), s.index["forever"].length
+	s.mu.RUnlock()
+	if keys != 1 || size != live {
+		t.Fatalf("expected expired records compacted away, size=%d keys=%d", size, keys)
+	}
+	if v, ok, _ := s.Get(ctx, "forever"); !ok || v.Title != "F" {
+		t.Fatalf("expected the live record to survive, got %v %v", v, ok)
+	}
+}
+
+func TestFileStore_BehindTieredCache(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t, filepath.Join(t.TempDir(), "tiered.log"), FileStoreOptions{})
+	tc := NewTieredCache[string, storedDoc](NewCache[string, storedDoc](Config{}), store, TieredOptions{})
+	tc.Set(ctx, "a", storedDoc{"A", 1}, -1)
+	tc.L1().Clear()
+	if v, ok, err := tc.Get(ctx, "a"); err != nil || !ok || v.Title != "A" {
+		t.Fatalf("expected read-through from disk, got %v %v %v", v, ok, err)
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	for name, codec := range map[string]Codec[storedDoc]{"json": JSONCodec[storedDoc]{}, "gob": GobCodec[storedDoc]{}} {
+		data, err := codec.Marshal(storedDoc{"x", 7})
+		if err != nil {
+			t.Fatalf("%s marshal: %v", name, err)
+		}
+		if v, err := codec.Unmarshal(data); err != nil || v != (storedDoc{"x", 7}) {
+			t.Fatalf("%s round trip: %v %v", name, v, err)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package httpcache provides net/http middleware that caches GET responses
+// in a polyfill.Cache, acting as a shared cache in front of a handler.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lofidv/polyfill/v2"
+)
+
+// Response is a cached response: status, headers and body. For responses
+// with Vary the request key holds an index entry with Status 0 and only
+// the Vary header, and each variant is stored under its own key.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Options configures Middleware.
+type Options struct {
+	// Key returns the cache key for a request. Defaults to host + URI.
+	Key func(*http.Request) string
+
+	// MaxBodyBytes skips storing responses with larger bodies (0 = no limit).
+	MaxBodyBytes int
+}
+
+// Middleware caches successful GET responses in c. Expiration follows the
+// response's Cache-Control: s-maxage or max-age become the entry's TTL and
+// without either the cache's DefaultTTL applies (ttl 0, as in Set).
+// Responses marked no-store, no-cache or private, or with max-age=0, are
+// not stored, nor are answers to requests with Authorization or responses
+// setting cookies unless marked public; Set-Cookie itself is never stored.
+// Responses with Vary are stored per variant of the named request headers,
+// and a request whose If-None-Match matches the cached ETag gets 304 Not
+// Modified. Requests with Cache-Control no-store bypass the cache
+// and no-cache skips the lookup but stores the fresh response. Served
+// responses carry X-Cache: HIT or MISS.
+//
+// Example:
+//
+//	pages := polyfill.NewCache[string, *httpcache.Response](polyfill.Config{
+//		DefaultTTL: time.Minute,
+//		MaxItems:   10_000,
+//	})
+//	http.ListenAndServe(":8080", httpcache.Middleware(pages, httpcache.Options{})(mux))
+func Middleware(c *polyfill.Cache[string, *Response], opts Options) func(http.Handler) http.Handler {
+	if opts.Key == nil {
+		opts.Key = defaultKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCC := parseCacheControl(r.Header)
+			if r.Method != http.MethodGet || reqCC.has("no-store") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := opts.Key(r)
+			if !reqCC.has("no-cache") {
+				if res, ok := lookup(c, key, r); ok {
+					w.Header().Set("X-Cache", "HIT")
+					serve(w, r, res)
+					return
+				}
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			rec := &recorder{ResponseWriter: w, max: opts.MaxBodyBytes}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if ttl, ok := storable(r, rec); ok {
+				res := &Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+				res.Header.Del("X-Cache")
+				res.Header.Del("Set-Cookie")
+				store(c, key, r, res, ttl)
+			}
+		})
+	}
+}
+
+// -------- internals --------
+
+func defaultKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+func lookup(c *polyfill.Cache[string, *Response], key string, r *http.Request) (*Response, bool) {
+	res, ok := c.Get(key)
+	if ok && res.Status == 0 {
+		res, ok = c.Get(variantKey(key, varyHeaders(res.Header), r))
+	}
+	return res, ok
+}
+
+func store(c *polyfill.Cache[string, *Response], key string, r *http.Request, res *Response, ttl time.Duration) {
+	vary := varyHeaders(res.Header)
+	if len(vary) == 0 {
+		c.Set(key, res, ttl)
+		return
+	}
+	c.Set(key, &Response{Header: http.Header{"Vary": {strings.Join(vary, ", ")}}}, ttl)
+	c.Set(variantKey(key, vary, r), res, ttl)
+}
+
+// serve writes res, or 304 Not Modified if the request already has it.
+func serve(w http.ResponseWriter, r *http.Request, res *Response) {
+	h := w.Header()
+	if etag := res.Header.Get("ETag"); etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		for _, k := range []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Vary"} {
+			if v := res.Header.Values(k); len(v) > 0 {
+				h[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	for k, v := range res.Header {
+		h[k] = v
+	}
+	w.WriteHeader(res.Status)
+	w.Write(res.Body)
+}
+
+// storable reports whether the recorded response may be cached and for how
+// long (ttl semantics like polyfill.Cache.Set).
+func storable(r *http.Request, rec *recorder) (time.Duration, bool) {
+	if rec.overflow || !cacheableStatus(rec.status) {
+		return 0, false
+	}
+	cc := parseCacheControl(rec.header)
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") {
+		return 0, false
+	}
+	if r.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") {
+		return 0, false
+	}
+	// cookies are per client; replaying them to others would leak sessions
+	if rec.header.Get("Set-Cookie") != "" && !cc.has("public") {
+		return 0, false
+	}
+	if slices.Contains(varyHeaders(rec.header), "*") {
+		return 0, false
+	}
+	age, ok := cc["s-maxage"]
+	if !ok {
+		age, ok = cc["max-age"]
+	}
+	if !ok {
+		return 0, true
+	}
+	secs, err := strconv.ParseInt(age, 10, 64)
+	if err != nil || secs <= 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// cacheableStatus reports the statuses cacheable by default (RFC 9110 15.1).
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusPermanentRedirect,
+		http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+type cacheControl map[string]string
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(val, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// varyHeaders returns the canonical header names in h's Vary, sorted.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func variantKey(key string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteByte(0)
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// etagMatches applies If-None-Match's weak comparison (RFC 9110 13.1.2).
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder passes the response through while keeping a copy to store.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header // snapshot taken at WriteHeader
+	body     bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = code
+	rec.header = rec.Header().Clone()
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.overflow {
+		if rec.max > 0 && rec.body.Len()+len(p) > rec.max {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,175 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2"
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+// countingHandler answers with the number of times it was called.
+func countingHandler(header http.Header) (http.Handler, *int) {
+	calls := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		fmt.Fprintf(w, "%s#%d", r.Header.Get("Accept-Language"), calls)
+	}), &calls
+}
+
+func get(h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_MaxAgeBecomesTTL(t *testing.T) {
+	clk := fakeclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := polyfill.NewCache[string, *Response](polyfill.Config{Clock: clk, DefaultTTL: time.Hour})
+	next, calls := countingHandler(http.Header{"Cache-Control": {"public, max-age=60"}, "X-Id": {"7"}})
+	h := Middleware(c, Options{})(next)
+
+	first := get(h, "/a")
+	second := get(h, "/a")
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected MISS then HIT, got %q %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if second.Body.String() != "#1" || second.Code != http.StatusOK || second.Header().Get("X-Id") != "7" {
+		t.Fatalf("expected the stored response, got %d %q %v", second.Code, second.Body, second.Header())
+	}
+	if ttl, _ := c.TTL("example.com/a"); ttl != time.Minute {
+		t.Fatalf("expected max-age as TTL, got %v", ttl)
+	}
+
+	clk.Advance(61 * time.Second)
+	if got := get(h, "/a").Body.String(); got != "#2" || *calls != 2 {
+		t.Fatalf("expected a refetch after max-age, got %q", got)
+	}
+}
+
+func TestMiddleware_DefaultTTLWithoutMaxAge(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{DefaultTTL: time.Hour})
+	next, _ := countingHandler(nil)
+	h := Middleware(c, Options{})(next)
+	get(h, "/a")
+	if ttl, _ := c.TTL("example.com/a"); ttl <= 59*time.Minute {
+		t.Fatalf("expected the cache's DefaultTTL, got %v", ttl)
+	}
+}
+
+func TestMiddleware_SkipsUncacheable(t *testing.T) {
+	cases := map[string]struct {
+		header  http.Header
+		request []string
+		method  string
+	}{
+		"no-store":     {header: http.Header{"Cache-Control": {"no-store"}}},
+		"private":      {header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		"max-age=0":    {header: http.Header{"Cache-Control": {"max-age=0"}}},
+		"vary *":       {header: http.Header{"Vary": {"*"}}},
+		"authorized":   {request: []string{"Authorization", "Bearer x"}},
+		"set-cookie":   {header: http.Header{"Set-Cookie": {"session=alice"}, "Cache-Control": {"max-age=60"}}},
+		"request skip": {request: []string{"Cache-Control", "no-store"}},
+		"post":         {method: http.MethodPost},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := polyfill.NewCache[string, *Response](polyfill.Config{})
+			next, calls := countingHandler(tc.header)
+			h := Middleware(c, Options{})(next)
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(tc.method, "/a", nil)
+				for j := 0; j+1 < len(tc.request); j += 2 {
+					req.Header.Set(tc.request[j], tc.request[j+1])
+				}
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			if *calls != 2 || c.Len() != 0 {
+				t.Fatalf("expected nothing cached, got %d calls and %d entries", *calls, c.Len())
+			}
+		})
+	}
+}
+
+func TestMiddleware_RequestNoCacheRefreshes(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{})
+	next, _ := countingHandler(nil)
+	h := Middleware(c, Options{})(next)
+	get(h, "/a")
+	if got := get(h, "/a", "Cache-Control", "no-cache").Body.String(); got != "#2" {
+		t.Fatalf("expected no-cache to reach the handler, got %q", got)
+	}
+	if got := get(h, "/a").Body.String(); got != "#2" {
+		t.Fatalf("expected the refreshed response to be stored, got %q", got)
+	}
+}
+
+func TestMiddleware_VaryStoresVariants(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{})
+	next, calls := countingHandler(http.Header{"Vary": {"accept-language"}})
+	h := Middleware(c, Options{})(next)
+
+	get(h, "/a", "Accept-Language", "en")
+	get(h, "/a", "Accept-Language", "de")
+	en := get(h, "/a", "Accept-Language", "en")
+	de := get(h, "/a", "Accept-Language", "de")
+	if en.Body.String() != "en#1" || de.Body.String() != "de#2" || *calls != 2 {
+		t.Fatalf("expected one cached response per language, got %q %q after %d calls", en.Body, de.Body, *calls)
+	}
+	if en.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected a hit for the stored variant")
+	}
+}
+
+func TestMiddleware_IfNoneMatchReturns304(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{})
+	next, _ := countingHandler(http.Header{"Etag": {`"v1"`}, "Cache-Control": {"max-age=60"}})
+	h := Middleware(c, Options{})(next)
+	get(h, "/a")
+
+	res := get(h, "/a", "If-None-Match", `"v0", W/"v1"`)
+	if res.Code != http.StatusNotModified || res.Body.Len() != 0 {
+		t.Fatalf("expected 304 without a body, got %d %q", res.Code, res.Body)
+	}
+	if res.Header().Get("ETag") != `"v1"` || res.Header().Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("expected validators on the 304, got %v", res.Header())
+	}
+	if res := get(h, "/a", "If-None-Match", `"v2"`); res.Code != http.StatusOK || res.Body.String() != "#1" {
+		t.Fatalf("expected the full cached response on a mismatch, got %d %q", res.Code, res.Body)
+	}
+}
+
+func TestMiddleware_MaxBodyBytes(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{})
+	next, _ := countingHandler(nil)
+	h := Middleware(c, Options{MaxBodyBytes: 1})(next)
+	if got := get(h, "/a").Body.String(); got != "#1" {
+		t.Fatalf("expected the response to pass through, got %q", got)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected the large body not to be stored")
+	}
+}
+
+func TestMiddleware_NeverReplaysSetCookie(t *testing.T) {
+	c := polyfill.NewCache[string, *Response](polyfill.Config{})
+	next, _ := countingHandler(http.Header{"Set-Cookie": {"session=alice"}, "Cache-Control": {"public, max-age=60"}})
+	h := Middleware(c, Options{})(next)
+	if got := get(h, "/a").Header().Get("Set-Cookie"); got != "session=alice" {
+		t.Fatalf("expected the first client to get its cookie, got %q", got)
+	}
+	res := get(h, "/a")
+	if res.Header().Get("X-Cache") != "HIT" || res.Header().Get("Set-Cookie") != "" {
+		t.Fatalf("expected a hit without the cookie, got %v", res.Header())
+	}
+}
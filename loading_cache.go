@@ -0,0 +1,180 @@
+package polyfill
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Loader loads the value for a key missing from a LoadingCache.
+// Return an error wrapping ErrNotFound when the key does not exist.
+type Loader[K comparable, V any] interface {
+	Load(ctx context.Context, key K) (V, error)
+}
+
+// LoaderFunc adapts an ordinary function to the Loader interface.
+type LoaderFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Load calls f(ctx, key).
+func (f LoaderFunc[K, V]) Load(ctx context.Context, key K) (V, error) {
+	return f(ctx, key)
+}
+
+// BulkLoader may be implemented by a Loader to fetch many keys in one call.
+// Keys absent from the returned map are treated as not found.
+type BulkLoader[K comparable, V any] interface {
+	LoadAll(ctx context.Context, keys []K) (map[K]V, error)
+}
+
+// LoadingOptions configures a LoadingCache.
+type LoadingOptions[K comparable, V any] struct {
+	CacheOptions[K, V]
+
+	// TTL applies to loaded values, with the same semantics as Set.
+	TTL time.Duration
+
+	// NegativeTTL remembers load errors for this long so repeated lookups
+	// of a failing key don't reach the loader (0 = disabled).
+	NegativeTTL time.Duration
+
+	// CacheError selects which errors are remembered. nil remembers all.
+	CacheError func(error) bool
+}
+
+// LoadingCache is a Cache that fills itself through a Loader, so call sites
+// only pass keys.
+type LoadingCache[K comparable, V any] struct {
+	cache    *Cache[K, V]
+	negative *Cache[K, error]
+	loader   Loader[K, V]
+	opts     LoadingOptions[K, V]
+}
+
+// NewLoadingCache creates a LoadingCache backed by loader. If loader also
+// implements BulkLoader, GetAll fetches missing keys in a single call.
+//
+// Example:
+//
+//	users := NewLoadingCache[int, User](LoaderFunc[int, User](repo.FindUser),
+//		LoadingOptions[int, User]{TTL: time.Minute, NegativeTTL: 5 * time.Second})
+//	u, err := users.Get(ctx, 42)
+func NewLoadingCache[K comparable, V any](loader Loader[K, V], opts LoadingOptions[K, V]) *LoadingCache[K, V] {
+	return &LoadingCache[K, V]{
+		cache: NewCacheWith(opts.CacheOptions),
+		negative: NewCacheWith(CacheOptions[K, error]{
+			Config: Config{MaxItems: opts.MaxItems, Shards: opts.Shards, Clock: opts.Clock},
+			Hasher: opts.Hasher,
+		}),
+		loader: loader,
+		opts:   opts,
+	}
+}
+
+// Get returns the cached value for key, loading it on a miss.
+// Concurrent misses for the same key share one Load call, which runs with
+// ctx's values but is not cancelled when ctx is.
+func (lc *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	// a remembered error only stands in for a missing entry, so values
+	// stored since (and stale ones kept for reloads) still win
+	if err, ok := lc.negative.Get(key); ok && !lc.cache.retained(key) {
+		var zero V
+		return zero, err
+	}
+	return lc.cache.GetOrSetCtx(ctx, key, func() (V, time.Duration, error) {
+		v, err := lc.loader.Load(context.WithoutCancel(ctx), key)
+		if err != nil {
+			lc.remember(key, err)
+		}
+		return v, lc.opts.TTL, err
+	})
+}
+
+// GetAll returns the values for keys, loading only the missing ones.
+// Keys that do not exist (ErrNotFound) are left out of the result; any
+// other load error is returned.
+func (lc *LoadingCache[K, V]) GetAll(ctx context.Context, keys []K) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	seen := make(map[K]struct{}, len(keys))
+	var missing []K
+	for _, k := range keys {
+		if _, dup := seen[k]; dup {
+			continue
+		}
+		seen[k] = struct{}{}
+		if v, ok := lc.cache.Get(k); ok {
+			result[k] = v
+			continue
+		}
+		if err, ok := lc.negative.Get(k); ok && !lc.cache.retained(k) {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		missing = append(missing, k)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	bulk, ok := lc.loader.(BulkLoader[K, V])
+	if !ok {
+		for _, k := range missing {
+			v, err := lc.Get(ctx, k)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			result[k] = v
+		}
+		return result, nil
+	}
+
+	loaded, err := lc.cache.loadAll(missing, lc.opts.TTL, func() (map[K]V, error) {
+		return bulk.LoadAll(ctx, missing)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range missing {
+		v, ok := loaded[k]
+		if !ok {
+			lc.remember(k, ErrNotFound)
+			continue
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
+// Invalidate drops key and any remembered error for it.
+func (lc *LoadingCache[K, V]) Invalidate(key K) {
+	lc.cache.Delete(key)
+	lc.negative.Delete(key)
+}
+
+// Cache exposes the underlying Cache, e.g. for Stats or manual Set.
+func (lc *LoadingCache[K, V]) Cache() *Cache[K, V] {
+	return lc.cache
+}
+
+// Close stops the underlying cache's background work.
+func (lc *LoadingCache[K, V]) Close() {
+	lc.cache.Close()
+	lc.negative.Close()
+}
+
+// remember caches err for key if negative caching applies to it. Failed
+// reloads of a kept entry (RefreshAfter, stale windows) are not remembered,
+// so the entry keeps being served.
+func (lc *LoadingCache[K, V]) remember(key K, err error) {
+	if lc.opts.NegativeTTL <= 0 || lc.cache.retained(key) {
+		return
+	}
+	if lc.opts.CacheError != nil && !lc.opts.CacheError(err) {
+		return
+	}
+	lc.negative.Set(key, err, lc.opts.NegativeTTL)
+}
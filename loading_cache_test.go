@@ -0,0 +1,253 @@
+package polyfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+type fakeLoader struct {
+	mu      sync.Mutex
+	data    map[string]int
+	loads   []string
+	batches [][]string
+}
+
+func (f *fakeLoader) Load(_ context.Context, key string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loads = append(f.loads, key)
+	v, ok := f.data[key]
+	if !ok {
+		return 0, fmt.Errorf("user %q: %w", key, ErrNotFound)
+	}
+	return v, nil
+}
+
+type fakeBulkLoader struct {
+	fakeLoader
+}
+
+func (f *fakeBulkLoader) LoadAll(_ context.Context, keys []string) (map[string]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, slices.Clone(keys))
+	out := map[string]int{}
+	for _, k := range keys {
+		if v, ok := f.data[k]; ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func TestLoadingCache_Get(t *testing.T) {
+	loader := &fakeLoader{data: map[string]int{"a": 1}}
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{TTL: -1})
+
+	for i := 0; i < 3; i++ {
+		if v, err := lc.Get(context.Background(), "a"); err != nil || v != 1 {
+			t.Fatalf("unexpected: %v %v", v, err)
+		}
+	}
+	if len(loader.loads) != 1 {
+		t.Fatalf("expected one load, got %v", loader.loads)
+	}
+
+	loader.data["a"] = 2
+	lc.Invalidate("a")
+	if v, _ := lc.Get(context.Background(), "a"); v != 2 {
+		t.Fatalf("expected reload after Invalidate, got %v", v)
+	}
+}
+
+func TestLoadingCache_NegativeCaching(t *testing.T) {
+	loader := &fakeLoader{data: map[string]int{}}
+	clk := fakeclock.New(time.Now())
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{
+		CacheOptions: CacheOptions[string, int]{Config: Config{Clock: clk}},
+		NegativeTTL:  time.Second,
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := lc.Get(context.Background(), "ghost"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if len(loader.loads) != 1 {
+		t.Fatalf("expected the error to be remembered, loads=%v", loader.loads)
+	}
+
+	clk.Advance(2 * time.Second)
+	lc.Get(context.Background(), "ghost")
+	if len(loader.loads) != 2 {
+		t.Fatalf("expected a new load after NegativeTTL, loads=%v", loader.loads)
+	}
+}
+
+func TestLoadingCache_FailedReloadKeepsValue(t *testing.T) {
+	boom := errors.New("boom")
+	for name, tc := range map[string]struct {
+		cfg   Config
+		after time.Duration
+	}{
+		"refresh-ahead":  {Config{DefaultTTL: 10 * time.Second, RefreshAfter: 5 * time.Second}, 6 * time.Second},
+		"stale-if-error": {Config{DefaultTTL: 10 * time.Second, StaleIfError: time.Minute}, 11 * time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := fakeclock.New(time.Now())
+			cfg := tc.cfg
+			cfg.Clock = clk
+			lc := NewLoadingCache[string, int](LoaderFunc[string, int](func(context.Context, string) (int, error) {
+				return 0, boom
+			}), LoadingOptions[string, int]{CacheOptions: CacheOptions[string, int]{Config: cfg}, NegativeTTL: time.Minute})
+			lc.cache.Set("k", 1, 0)
+
+			clk.Advance(tc.after)
+			for i := 0; i < 2; i++ {
+				if v, err := lc.Get(context.Background(), "k"); err != nil || v != 1 {
+					t.Fatalf("expected the kept value after a failed reload, got %v %v", v, err)
+				}
+				waitNoLoads(lc.cache)
+			}
+			if lc.negative.Len() != 0 {
+				t.Fatalf("expected failed reloads not to be remembered")
+			}
+		})
+	}
+}
+
+func TestLoadingCache_SetOverridesRememberedError(t *testing.T) {
+	loader := &fakeLoader{data: map[string]int{}}
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{NegativeTTL: time.Minute})
+	lc.Get(context.Background(), "k")
+	lc.Cache().Set("k", 5, -1)
+	if v, err := lc.Get(context.Background(), "k"); err != nil || v != 5 {
+		t.Fatalf("expected the stored value over the remembered error, got %v %v", v, err)
+	}
+}
+
+func TestLoadingCache_CacheErrorFilter(t *testing.T) {
+	transient := errors.New("timeout")
+	calls := 0
+	lc := NewLoadingCache[string, int](LoaderFunc[string, int](func(context.Context, string) (int, error) {
+		calls++
+		return 0, transient
+	}), LoadingOptions[string, int]{
+		NegativeTTL: time.Minute,
+		CacheError:  func(err error) bool { return errors.Is(err, ErrNotFound) },
+	})
+	lc.Get(context.Background(), "k")
+	lc.Get(context.Background(), "k")
+	if calls != 2 {
+		t.Fatalf("transient errors must not be cached, calls=%d", calls)
+	}
+}
+
+func TestLoadingCache_GetAllBulk(t *testing.T) {
+	loader := &fakeBulkLoader{fakeLoader{data: map[string]int{"a": 1, "b": 2, "c": 3}}}
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{TTL: -1, NegativeTTL: time.Minute})
+	lc.Cache().Set("a", 10, -1)
+
+	got, err := lc.GetAll(context.Background(), []string{"a", "b", "c", "zz", "b"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want := map[string]int{"a": 10, "b": 2, "c": 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if len(loader.batches) != 1 || !reflect.DeepEqual(loader.batches[0], []string{"b", "c", "zz"}) {
+		t.Fatalf("expected one batch of missing keys, got %v", loader.batches)
+	}
+
+	// everything is now cached, including the miss
+	if _, err := lc.GetAll(context.Background(), []string{"b", "zz"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(loader.batches) != 1 {
+		t.Fatalf("expected no further batches, got %v", loader.batches)
+	}
+	if _, err := lc.Get(context.Background(), "zz"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected remembered ErrNotFound, got %v", err)
+	}
+}
+
+// gatedBulkLoader blocks LoadAll until release is closed.
+type gatedBulkLoader struct {
+	fakeBulkLoader
+	entered, release chan struct{}
+}
+
+func (g *gatedBulkLoader) LoadAll(ctx context.Context, keys []string) (map[string]int, error) {
+	close(g.entered)
+	<-g.release
+	return g.fakeBulkLoader.LoadAll(ctx, keys)
+}
+
+func TestLoadingCache_GetAllKeepsConcurrentWrites(t *testing.T) {
+	loader := &gatedBulkLoader{
+		fakeBulkLoader{fakeLoader{data: map[string]int{"a": 1, "b": 2, "c": 3}}},
+		make(chan struct{}), make(chan struct{}),
+	}
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{TTL: -1})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lc.GetAll(context.Background(), []string{"a", "b", "c"})
+	}()
+	<-loader.entered
+	lc.Invalidate("b")
+	lc.Cache().Set("c", 30, -1)
+	close(loader.release)
+	<-done
+
+	if v, _ := lc.Cache().Get("a"); v != 1 {
+		t.Fatalf("expected the loaded value stored, got %v", v)
+	}
+	if lc.Cache().Has("b") {
+		t.Fatalf("expected the invalidated key to stay out")
+	}
+	if v, _ := lc.Cache().Get("c"); v != 30 {
+		t.Fatalf("expected the concurrent Set to win, got %v", v)
+	}
+	if s := lc.Cache().Stats(); s.LoadSuccesses != 1 || s.TotalLoadTime <= 0 {
+		t.Fatalf("expected the bulk load in the stats, got %+v", s)
+	}
+}
+
+func TestLoadingCache_GetAllPrefersKeptValueOverRememberedError(t *testing.T) {
+	clk := fakeclock.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lc := NewLoadingCache[string, int](&fakeLoader{data: map[string]int{}}, LoadingOptions[string, int]{
+		CacheOptions: CacheOptions[string, int]{Config: Config{
+			DefaultTTL:           10 * time.Second,
+			StaleWhileRevalidate: time.Minute,
+			Clock:                clk,
+		}},
+		NegativeTTL: time.Minute,
+	})
+	lc.Get(context.Background(), "k") // remembers ErrNotFound
+	lc.Cache().Set("k", 1, 0)
+	clk.Advance(11 * time.Second) // stale, still kept
+
+	got, err := lc.GetAll(context.Background(), []string{"k"})
+	waitNoLoads(lc.cache)
+	if err != nil || !reflect.DeepEqual(got, map[string]int{"k": 1}) {
+		t.Fatalf("expected the stale value over the remembered error, got %v %v", got, err)
+	}
+}
+
+func TestLoadingCache_GetAllWithoutBulk(t *testing.T) {
+	loader := &fakeLoader{data: map[string]int{"a": 1, "b": 2}}
+	lc := NewLoadingCache[string, int](loader, LoadingOptions[string, int]{})
+	got, err := lc.GetAll(context.Background(), []string{"a", "b", "missing"})
+	if err != nil || !reflect.DeepEqual(got, map[string]int{"a": 1, "b": 2}) {
+		t.Fatalf("unexpected: %v %v", got, err)
+	}
+}
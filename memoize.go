@@ -0,0 +1,52 @@
+package polyfill
+
+import (
+	"context"
+	"time"
+)
+
+// Memoize wraps fn so results are cached per argument in a new Cache built
+// from cfg (DefaultTTL, MaxItems, ...). Concurrent calls with the same
+// argument share one fn call. The Cache is returned for invalidation and
+// must be closed if cfg starts background work.
+//
+// Example:
+//
+//	price, prices := Memoize(lookupPrice, Config{DefaultTTL: time.Minute, MaxItems: 1000})
+//	p := price("sku-1") // computed
+//	p = price("sku-1")  // cached
+//	prices.Delete("sku-1")
+func Memoize[K comparable, V any](fn func(K) V, cfg Config) (func(K) V, *Cache[K, V]) {
+	c := NewCache[K, V](cfg)
+	return func(key K) V {
+		v, _ := c.GetOrSet(key, func() (V, time.Duration, error) {
+			return fn(key), 0, nil
+		})
+		return v
+	}, c
+}
+
+// MemoizeErr is like Memoize for functions that can fail. Errors are
+// returned to every caller sharing the call and are not cached.
+func MemoizeErr[K comparable, V any](fn func(K) (V, error), cfg Config) (func(K) (V, error), *Cache[K, V]) {
+	c := NewCache[K, V](cfg)
+	return func(key K) (V, error) {
+		return c.GetOrSet(key, func() (V, time.Duration, error) {
+			v, err := fn(key)
+			return v, 0, err
+		})
+	}, c
+}
+
+// MemoizeCtx is like MemoizeErr for context-aware functions. A caller whose
+// ctx is done stops waiting with ctx.Err(); the shared call runs with ctx's
+// values but is not cancelled, so its result is still cached.
+func MemoizeCtx[K comparable, V any](fn func(context.Context, K) (V, error), cfg Config) (func(context.Context, K) (V, error), *Cache[K, V]) {
+	c := NewCache[K, V](cfg)
+	return func(ctx context.Context, key K) (V, error) {
+		return c.GetOrSetCtx(ctx, key, func() (V, time.Duration, error) {
+			v, err := fn(context.WithoutCancel(ctx), key)
+			return v, 0, err
+		})
+	}, c
+}
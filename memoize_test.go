@@ -0,0 +1,99 @@
+package polyfill
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+func TestMemoize_CachesAndDeduplicates(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	square, cache := Memoize(func(n int) int {
+		calls.Add(1)
+		<-release
+		return n * n
+	}, Config{MaxItems: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := square(3); v != 9 {
+				t.Errorf("expected 9, got %d", v)
+			}
+		}()
+	}
+	for cache.stats.misses.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	square(3)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one shared call, got %d", n)
+	}
+
+	cache.Delete(3)
+	square(3)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected a recompute after invalidation, got %d calls", n)
+	}
+}
+
+func TestMemoize_UsesDefaultTTL(t *testing.T) {
+	calls := 0
+	clk := fakeclock.New(time.Now())
+	now, _ := Memoize(func(string) int { calls++; return calls }, Config{DefaultTTL: time.Second, Clock: clk})
+	now("k")
+	now("k")
+	clk.Advance(2 * time.Second)
+	if v := now("k"); v != 2 {
+		t.Fatalf("expected recompute after the TTL, got %d", v)
+	}
+}
+
+func TestMemoizeErr_DoesNotCacheErrors(t *testing.T) {
+	boom := errors.New("boom")
+	fail := true
+	parse, _ := MemoizeErr(func(s string) (int, error) {
+		if fail {
+			return 0, boom
+		}
+		return len(s), nil
+	}, Config{})
+	if _, err := parse("abc"); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	fail = false
+	if v, err := parse("abc"); err != nil || v != 3 {
+		t.Fatalf("expected a retry after the error, got %v %v", v, err)
+	}
+}
+
+func TestMemoizeCtx_CallerCancelDoesNotCancelLoad(t *testing.T) {
+	release := make(chan struct{})
+	fetch, cache := MemoizeCtx(func(ctx context.Context, id int) (string, error) {
+		<-release
+		return "user", ctx.Err()
+	}, Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetch(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to stop waiting, got %v", err)
+	}
+	close(release)
+	if v, err := fetch(context.Background(), 1); err != nil || v != "user" {
+		t.Fatalf("expected the shared load to finish, got %v %v", v, err)
+	}
+	if !cache.Has(1) {
+		t.Fatalf("expected the result to be cached")
+	}
+}
@@ -1,6 +1,9 @@
 package polyfill
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // === PARALLEL EXECUTION ===
 
@@ -8,6 +11,21 @@ import "sync"
 type ParallelOptions struct {
 	Workers int  // number of goroutines (0 = number of elements)
 	Ordered bool // preserve order (default true)
+
+	// Pool, when set, runs the work on a shared long-lived Pool instead of
+	// per-call goroutines. Workers then caps how many goroutines one call
+	// uses: the caller itself plus up to Workers-1 pool workers, so total
+	// concurrency is the pool's Size plus the number of concurrent callers.
+	Pool *Pool
+
+	// RateLimit, when set, makes every call to the mapping function wait for
+	// a token first. Share one limiter across chains to enforce a global quota.
+	RateLimit *RateLimiter
+
+	// MaxInFlight, when set, makes every call to the mapping function hold
+	// a slot of the limiter while it runs. Share one limiter across chains to
+	// cap their combined concurrency.
+	MaxInFlight *InFlightLimiter
 }
 
 // ParallelSeq wraps a Seq for parallel operations
@@ -35,34 +53,20 @@ func (s *Seq[T]) Parallel(opts ...ParallelOptions) *ParallelSeq[T] {
 // Map transforms elements concurrently (same type)
 func (p *ParallelSeq[T]) Map(f func(T) T) *Seq[T] {
 	result := make([]T, len(p.seq.elements))
-	var wg sync.WaitGroup
-
-	workers := p.opts.Workers
-	if workers > len(p.seq.elements) {
-		workers = len(p.seq.elements)
-	}
-
-	jobs := make(chan int, len(p.seq.elements))
-
-	for w := 0; w < workers; w++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for idx := range jobs {
-				result[idx] = f(p.seq.elements[idx])
-			}
-		}()
-	}
-
-	for i := range p.seq.elements {
-		jobs <- i
-	}
-	close(jobs)
-
-	wg.Wait()
+	p.opts.run(len(p.seq.elements), func(idx int) {
+		result[idx] = f(p.seq.elements[idx])
+	})
 	return From(result)
 }
 
+// MapE transforms elements concurrently with error handling (same type).
+// Once an element fails, elements after it that have not started are
+// skipped and the error of the lowest failing index is stored in the
+// returned Seq.
+func (p *ParallelSeq[T]) MapE(f func(T) (T, error)) *Seq[T] {
+	return ParallelMapToE(p, f)
+}
+
 // Slice returns the result as a slice
 func (p *ParallelSeq[T]) Slice() []T {
 	return p.seq.elements
@@ -71,30 +75,90 @@ func (p *ParallelSeq[T]) Slice() []T {
 // ParallelMapTo transforms elements concurrently with type change
 func ParallelMapTo[T any, R any](p *ParallelSeq[T], f func(T) R) *Seq[R] {
 	result := make([]R, len(p.seq.elements))
-	var wg sync.WaitGroup
+	p.opts.run(len(p.seq.elements), func(idx int) {
+		result[idx] = f(p.seq.elements[idx])
+	})
+	return From(result)
+}
+
+// ParallelMapToE transforms elements concurrently with type change and error handling
+func ParallelMapToE[T any, R any](p *ParallelSeq[T], f func(T) (R, error)) *Seq[R] {
+	if p.seq.err != nil {
+		return &Seq[R]{err: p.seq.err}
+	}
+
+	n := len(p.seq.elements)
+	result := make([]R, n)
+	errs := make([]error, n)
+	// lowest failing index so far; only later elements may be skipped, so
+	// every element before the final one has run
+	var lowest atomic.Int64
+	lowest.Store(int64(n))
+	p.opts.run(n, func(idx int) {
+		if int64(idx) > lowest.Load() {
+			return
+		}
+		val, err := f(p.seq.elements[idx])
+		if err != nil {
+			errs[idx] = err
+			for cur := lowest.Load(); int64(idx) < cur && !lowest.CompareAndSwap(cur, int64(idx)); cur = lowest.Load() {
+			}
+			return
+		}
+		result[idx] = val
+	})
 
-	workers := p.opts.Workers
-	if workers > len(p.seq.elements) {
-		workers = len(p.seq.elements)
+	if i := lowest.Load(); i < int64(n) {
+		return &Seq[R]{err: errs[i]}
+	}
+	return From(result)
+}
+
+// run invokes fn for every index in [0, n) concurrently and waits for completion
+func (o ParallelOptions) run(n int, fn func(idx int)) {
+	workers := o.Workers
+	if workers > n {
+		workers = n
+	}
+	if o.MaxInFlight != nil {
+		bounded := fn
+		fn = func(idx int) {
+			o.MaxInFlight.Acquire()
+			defer o.MaxInFlight.Release()
+			bounded(idx)
+		}
+	}
+	// rate waits happen before taking an in-flight slot
+	if o.RateLimit != nil {
+		limited := fn
+		fn = func(idx int) {
+			o.RateLimit.Wait()
+			limited(idx)
+		}
 	}
 
-	jobs := make(chan int, len(p.seq.elements))
+	if o.Pool != nil {
+		o.Pool.run(n, workers, fn)
+		return
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int, n)
 
 	for w := 0; w < workers; w++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for idx := range jobs {
-				result[idx] = f(p.seq.elements[idx])
+				fn(idx)
 			}
 		}()
 	}
 
-	for i := range p.seq.elements {
+	for i := 0; i < n; i++ {
 		jobs <- i
 	}
 	close(jobs)
 
 	wg.Wait()
-	return From(result)
 }
@@ -0,0 +1,124 @@
+package polyfill
+
+import "slices"
+
+// minParallelSortRun is the smallest run handed to a worker. Inputs shorter
+// than two runs are sorted sequentially since goroutine and merge overhead
+// outweighs the speedup there (see BenchmarkParallelSort).
+const minParallelSortRun = 8192
+
+// Sort returns a sorted copy of the sequence using a parallel merge sort.
+// Runs are sorted concurrently (honoring Workers and Pool) and then merged
+// pairwise. Small inputs fall back to the sequential Sort. Like Seq.Sort,
+// the relative order of equal elements is unspecified; use SortStable when
+// it matters.
+//
+// Example:
+//
+//	From(bigSlice).Parallel().Sort(func(a, b int) bool { return a < b }).Slice()
+func (p *ParallelSeq[T]) Sort(less func(a, b T) bool) *Seq[T] {
+	return p.sort(less, false)
+}
+
+// SortStable is like Sort but keeps equal elements in their original order,
+// producing exactly the result of a sequential stable sort.
+func (p *ParallelSeq[T]) SortStable(less func(a, b T) bool) *Seq[T] {
+	return p.sort(less, true)
+}
+
+func (p *ParallelSeq[T]) sort(less func(a, b T) bool, stable bool) *Seq[T] {
+	if p.seq.err != nil {
+		return p.seq
+	}
+
+	n := len(p.seq.elements)
+	runs := p.opts.Workers
+	if maxRuns := n / minParallelSortRun; runs > maxRuns {
+		runs = maxRuns
+	}
+	if runs < 2 {
+		if !stable {
+			return p.seq.Sort(less)
+		}
+		result := slices.Clone(p.seq.elements)
+		slices.SortStableFunc(result, lessToCmp(less))
+		return From(result)
+	}
+
+	// run boundaries: run i covers src[bounds[i]:bounds[i+1]]
+	bounds := make([]int, runs+1)
+	for i := range bounds {
+		bounds[i] = i * n / runs
+	}
+
+	src := slices.Clone(p.seq.elements)
+	dst := make([]T, n)
+	cmp := lessToCmp(less)
+
+	// sorting a run is CPU work, so a rate limit on the options does not apply
+	opts := p.opts
+	opts.RateLimit = nil
+
+	opts.run(runs, func(i int) {
+		run := src[bounds[i]:bounds[i+1]]
+		if stable {
+			slices.SortStableFunc(run, cmp)
+		} else {
+			slices.SortFunc(run, cmp)
+		}
+	})
+
+	// merge adjacent runs pairwise until a single run remains
+	for len(bounds) > 2 {
+		pairs := (len(bounds) - 1) / 2
+		opts.run(pairs, func(i int) {
+			lo, mid, hi := bounds[2*i], bounds[2*i+1], bounds[2*i+2]
+			mergeRuns(dst[lo:hi], src[lo:mid], src[mid:hi], less)
+		})
+		next := make([]int, 0, pairs+2)
+		for i := 0; i < len(bounds); i += 2 {
+			next = append(next, bounds[i])
+		}
+		if (len(bounds)-1)%2 == 1 {
+			// odd run out: carry it over unchanged
+			lo, hi := bounds[len(bounds)-2], bounds[len(bounds)-1]
+			copy(dst[lo:hi], src[lo:hi])
+			next = append(next, hi)
+		}
+		bounds = next
+		src, dst = dst, src
+	}
+
+	return From(src)
+}
+
+// mergeRuns merges sorted a and b into out, taking from a on ties so the
+// merge is stable.
+func mergeRuns[T any](out, a, b []T, less func(a, b T) bool) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if less(b[j], a[i]) {
+			out[k] = b[j]
+			j++
+		} else {
+			out[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(out[k:], a[i:])
+	copy(out[k:], b[j:])
+}
+
+// lessToCmp adapts a less function to the three-way form used by slices
+func lessToCmp[T any](less func(a, b T) bool) func(a, b T) int {
+	return func(a, b T) int {
+		if less(a, b) {
+			return -1
+		}
+		if less(b, a) {
+			return 1
+		}
+		return 0
+	}
+}
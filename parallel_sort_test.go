@@ -0,0 +1,83 @@
+package polyfill
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func randomInts(n int, seed int64) []int {
+	r := rand.New(rand.NewSource(seed))
+	out := make([]int, n)
+	for i := range out {
+		out[i] = r.Intn(n)
+	}
+	return out
+}
+
+func TestParallelSort_MatchesSequential(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	for _, n := range []int{0, 1, 10, minParallelSortRun*2 + 1, minParallelSortRun*7 + 3} {
+		for _, workers := range []int{1, 2, 3, 8} {
+			in := randomInts(n, int64(n))
+			orig := slices.Clone(in)
+			want := From(in).Sort(less).Slice()
+			got := From(in).Parallel(ParallelOptions{Workers: workers}).Sort(less).Slice()
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("n=%d workers=%d: parallel sort differs from sequential", n, workers)
+			}
+			if !reflect.DeepEqual(in, orig) {
+				t.Fatalf("n=%d workers=%d: input was mutated", n, workers)
+			}
+		}
+	}
+}
+
+func TestParallelSortStable(t *testing.T) {
+	type rec struct{ key, pos int }
+	n := minParallelSortRun*5 + 11
+	keys := randomInts(n, 42)
+	in := make([]rec, n)
+	for i, k := range keys {
+		in[i] = rec{key: k % 100, pos: i}
+	}
+	less := func(a, b rec) bool { return a.key < b.key }
+
+	want := slices.Clone(in)
+	slices.SortStableFunc(want, func(a, b rec) int { return a.key - b.key })
+
+	pool := NewPool(4, 0)
+	defer pool.Close()
+	got := From(in).Parallel(ParallelOptions{Workers: 4, Pool: pool}).SortStable(less).Slice()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parallel stable sort differs from sequential stable sort")
+	}
+}
+
+func TestParallelSort_PropagatesError(t *testing.T) {
+	s := From([]int{3, 1, 2}).MapE(func(n int) (int, error) { return 0, fmt.Errorf("boom") })
+	if err := s.Parallel().Sort(func(a, b int) bool { return a < b }).Err(); err == nil {
+		t.Fatalf("expected error to propagate")
+	}
+}
+
+// BenchmarkParallelSort compares sequential and parallel sorting across sizes
+// to locate the crossover point; run with -cpu=1,4,8 on the target machine.
+func BenchmarkParallelSort(b *testing.B) {
+	less := func(a, b int) bool { return a < b }
+	for _, n := range []int{1_000, 10_000, 100_000, 1_000_000} {
+		in := randomInts(n, 1)
+		b.Run(fmt.Sprintf("sequential/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				From(in).Sort(less)
+			}
+		})
+		b.Run(fmt.Sprintf("parallel/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				From(in).Parallel(ParallelOptions{Workers: 8}).Sort(less)
+			}
+		})
+	}
+}
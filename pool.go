@@ -0,0 +1,170 @@
+package polyfill
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrPoolClosed is returned when work is submitted to a closed Pool.
+	ErrPoolClosed = errors.New("pool: closed")
+	// ErrPoolFull is returned by TrySubmit when no worker or queue slot is free.
+	ErrPoolFull = errors.New("pool: queue full")
+)
+
+// Pool is a fixed set of long-lived goroutines that can be shared by many
+// parallel operations through ParallelOptions.Pool, bounding how many helper
+// goroutines they use process-wide without spawning any per call. Each
+// calling goroutine also processes items itself, so at most Size() plus the
+// number of goroutines currently inside a pooled operation run at once.
+type Pool struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    []func()
+	limit    int // max tasks waiting beyond idle workers
+	idle     int // workers blocked waiting for a task
+	size     int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewPool starts a pool with size workers and room for queue pending tasks.
+// size<=0 uses runtime.GOMAXPROCS(0). queue<=0 means Submit blocks until a
+// worker is free to take the task. Parallel operations run on the pool add
+// their calling goroutine to the size workers (see Pool).
+//
+// Example:
+//
+//	pool := NewPool(8, 64)
+//	defer pool.Close()
+//	From(items).Parallel(ParallelOptions{Pool: pool}).Map(work)
+func NewPool(size, queue int) *Pool {
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := &Pool{limit: queue, size: size}
+	p.notEmpty = sync.NewCond(&p.mu)
+	p.notFull = sync.NewCond(&p.mu)
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Size returns the number of workers.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Submit queues task for execution, blocking while the queue is full.
+// Returns ErrPoolClosed if the pool has been closed.
+func (p *Pool) Submit(task func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for !p.closed && !p.hasRoomLocked() {
+		p.notFull.Wait()
+	}
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.enqueueLocked(task)
+	return nil
+}
+
+// TrySubmit queues task only if it can be accepted without blocking.
+// Returns ErrPoolFull or ErrPoolClosed otherwise.
+func (p *Pool) TrySubmit(task func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if !p.hasRoomLocked() {
+		return ErrPoolFull
+	}
+	p.enqueueLocked(task)
+	return nil
+}
+
+// Close stops accepting work, waits for queued tasks to finish and
+// releases the workers. It is safe to call more than once but must not
+// be called from inside a task.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.notEmpty.Broadcast()
+	p.notFull.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// run invokes fn for every index in [0, n), handing up to workers-1 helpers
+// to the pool while the calling goroutine processes indices itself.
+// Because the caller always makes progress, run never deadlocks when the
+// pool is saturated, closed, or used from inside one of its own tasks.
+func (p *Pool) run(n, workers int, fn func(int)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(n)
+	work := func() {
+		for {
+			i := int(next.Add(1) - 1)
+			if i >= n {
+				return
+			}
+			fn(i)
+			wg.Done()
+		}
+	}
+	for w := 1; w < workers && w < n; w++ {
+		if p.TrySubmit(work) != nil {
+			break
+		}
+	}
+	work()
+	wg.Wait()
+}
+
+// -------- internals --------
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	p.mu.Lock()
+	for {
+		for len(p.queue) == 0 && !p.closed {
+			p.idle++
+			p.notFull.Broadcast()
+			p.notEmpty.Wait()
+			p.idle--
+		}
+		if len(p.queue) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		task := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.notFull.Broadcast()
+		p.mu.Unlock()
+
+		task()
+
+		p.mu.Lock()
+	}
+}
+
+func (p *Pool) hasRoomLocked() bool {
+	return len(p.queue) < p.limit+p.idle
+}
+
+func (p *Pool) enqueueLocked(task func()) {
+	p.queue = append(p.queue, task)
+	p.notEmpty.Signal()
+}
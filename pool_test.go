@@ -0,0 +1,130 @@
+package polyfill
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPool_BoundsConcurrency(t *testing.T) {
+	p := NewPool(3, 100)
+	defer p.Close()
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		if err := p.Submit(func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	close(release)
+	wg.Wait()
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("expected at most 3 concurrent tasks, saw %d", got)
+	}
+}
+
+func TestPool_CloseDrainsQueue(t *testing.T) {
+	p := NewPool(1, 10)
+	var done atomic.Int32
+	for i := 0; i < 5; i++ {
+		if err := p.Submit(func() { done.Add(1) }); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	p.Close()
+	if got := done.Load(); got != 5 {
+		t.Fatalf("expected 5 tasks run before Close returned, got %d", got)
+	}
+	if err := p.Submit(func() {}); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+	p.Close() // idempotent
+}
+
+func TestPool_TrySubmitFull(t *testing.T) {
+	p := NewPool(1, 0)
+	defer p.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Submit(func() { close(started); <-release }); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	<-started
+	if err := p.TrySubmit(func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("expected ErrPoolFull, got %v", err)
+	}
+	close(release)
+}
+
+func TestParallel_WithPool(t *testing.T) {
+	p := NewPool(4, 16)
+	defer p.Close()
+
+	nums := make([]int, 100)
+	want := make([]int, 100)
+	for i := range nums {
+		nums[i] = i
+		want[i] = i * i
+	}
+	got := From(nums).
+		Parallel(ParallelOptions{Pool: p}).
+		Map(func(n int) int { return n * n }).
+		Slice()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: %v", got)
+	}
+
+	strs := ParallelMapTo(From([]int{1, 2, 3}).Parallel(ParallelOptions{Pool: p, Workers: 2}),
+		func(n int) string { return string(rune('a' + n - 1)) }).Slice()
+	if !reflect.DeepEqual(strs, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected result: %v", strs)
+	}
+}
+
+func TestParallel_NestedPoolDoesNotDeadlock(t *testing.T) {
+	p := NewPool(2, 0)
+	defer p.Close()
+
+	outer := From([]int{1, 2, 3, 4}).
+		Parallel(ParallelOptions{Pool: p}).
+		Map(func(n int) int {
+			inner := From([]int{n, n, n}).
+				Parallel(ParallelOptions{Pool: p}).
+				Map(func(m int) int { return m * 2 }).
+				Slice()
+			return inner[0] + inner[1] + inner[2]
+		}).
+		Slice()
+	if !reflect.DeepEqual(outer, []int{6, 12, 18, 24}) {
+		t.Fatalf("unexpected result: %v", outer)
+	}
+}
+
+func TestParallel_ClosedPoolStillCompletes(t *testing.T) {
+	p := NewPool(2, 0)
+	p.Close()
+	got := From([]int{1, 2, 3}).
+		Parallel(ParallelOptions{Pool: p}).
+		Map(func(n int) int { return n + 1 }).
+		Slice()
+	if !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Fatalf("unexpected result: %v", got)
+	}
+}
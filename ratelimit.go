@@ -0,0 +1,126 @@
+package polyfill
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket allowing a steady number of operations per
+// second with short bursts. A single limiter may be shared by many parallel
+// chains (through ParallelOptions.RateLimit) to respect one global quota.
+type RateLimiter struct {
+	// Clock supplies the time and, if it is a Sleeper, the waits (nil =
+	// system clock). Set it before the limiter is first used.
+	Clock Clock
+
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perSecond operations per second
+// and bursts of up to burst operations. burst<1 is treated as 1 and
+// perSecond<=0 disables limiting. The bucket starts full.
+//
+// Example:
+//
+//	limiter := NewRateLimiter(50, 10) // 50 req/s, bursts of 10
+//	ParallelMapTo(From(ids).Parallel(ParallelOptions{RateLimit: limiter}), fetch)
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Wait blocks until an operation is permitted.
+// Waiters are served in the order they reserved a token.
+func (r *RateLimiter) Wait() {
+	if d := r.reserve(); d > 0 {
+		sleepOn(clockOr(r.Clock), d)
+	}
+}
+
+// Allow reports whether an operation may happen now, consuming a token if so.
+func (r *RateLimiter) Allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rate <= 0 {
+		return true
+	}
+	r.refillLocked()
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// InFlightLimiter caps how many operations run at the same time. Like a
+// RateLimiter, one limiter may be shared by many parallel chains (through
+// ParallelOptions.MaxInFlight) to respect one global concurrency quota.
+type InFlightLimiter struct {
+	slots chan struct{}
+}
+
+// NewInFlightLimiter allows up to n operations at once. n<=0 disables
+// limiting.
+//
+// Example:
+//
+//	partner := NewInFlightLimiter(4) // shared by every chain calling the partner
+//	ParallelMapTo(From(ids).Parallel(ParallelOptions{Workers: 32, MaxInFlight: partner}), fetch)
+func NewInFlightLimiter(n int) *InFlightLimiter {
+	if n <= 0 {
+		return &InFlightLimiter{}
+	}
+	return &InFlightLimiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until an operation may start. Each Acquire must be paired
+// with a Release once the operation is done.
+func (l *InFlightLimiter) Acquire() {
+	if l.slots != nil {
+		l.slots <- struct{}{}
+	}
+}
+
+// Release ends an operation started with Acquire.
+func (l *InFlightLimiter) Release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait before the token becomes valid.
+func (r *RateLimiter) reserve() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rate <= 0 {
+		return 0
+	}
+	r.refillLocked()
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+func (r *RateLimiter) refillLocked() {
+	now := clockOr(r.Clock).Now()
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+}
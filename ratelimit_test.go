@@ -0,0 +1,110 @@
+package polyfill
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+// newFakeLimiter returns a limiter on a fake clock, so Wait advances the
+// clock instead of blocking.
+func newFakeLimiter(perSecond float64, burst int) (*RateLimiter, *fakeclock.Clock) {
+	clk := fakeclock.New(time.Unix(0, 0))
+	r := NewRateLimiter(perSecond, burst)
+	r.Clock = clk
+	return r, clk
+}
+
+// slept is how far Wait moved clk.
+func slept(clk *fakeclock.Clock) time.Duration {
+	return clk.Now().Sub(time.Unix(0, 0))
+}
+
+func TestRateLimiter_BurstThenSteady(t *testing.T) {
+	r, clk := newFakeLimiter(5, 2)
+
+	// burst of 2 is free, the remaining 8 are spaced 200ms apart
+	for i := 0; i < 10; i++ {
+		r.Wait()
+	}
+	if want := 1600 * time.Millisecond; slept(clk) != want {
+		t.Fatalf("expected total wait %v, got %v", want, slept(clk))
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	r, clk := newFakeLimiter(2, 1)
+	if !r.Allow() {
+		t.Fatalf("first call should be allowed")
+	}
+	if r.Allow() {
+		t.Fatalf("second call should be throttled")
+	}
+	clk.Advance(500 * time.Millisecond)
+	if !r.Allow() {
+		t.Fatalf("call should be allowed after refill")
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	r, clk := newFakeLimiter(0, 1)
+	for i := 0; i < 5; i++ {
+		r.Wait()
+		if !r.Allow() {
+			t.Fatalf("disabled limiter should always allow")
+		}
+	}
+	if slept(clk) != 0 {
+		t.Fatalf("disabled limiter should never wait, waited %v", slept(clk))
+	}
+}
+
+func TestParallel_RateLimit(t *testing.T) {
+	r, clk := newFakeLimiter(10, 1)
+	var calls atomic.Int32
+	got := From([]int{1, 2, 3, 4, 5}).
+		Parallel(ParallelOptions{Workers: 1, RateLimit: r}).
+		Map(func(n int) int { calls.Add(1); return n * 10 }).
+		Slice()
+	if len(got) != 5 || got[4] != 50 || calls.Load() != 5 {
+		t.Fatalf("unexpected result: %v", got)
+	}
+	if want := 400 * time.Millisecond; slept(clk) != want {
+		t.Fatalf("expected total wait %v, got %v", want, slept(clk))
+	}
+}
+
+func TestParallel_MaxInFlight(t *testing.T) {
+	var running, peak atomic.Int32
+	nums := make([]int, 50)
+	limiter := NewInFlightLimiter(3)
+	track := func(n int) int {
+		cur := running.Add(1)
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return n
+	}
+
+	// two chains sharing one limiter stay within its quota together
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ParallelMapTo(From(nums).Parallel(ParallelOptions{Workers: 20, MaxInFlight: limiter}), track)
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("expected at most 3 in flight, saw %d", got)
+	}
+}
@@ -0,0 +1,103 @@
+package polyfill
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how a failing element function is retried.
+// The zero value makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first.
+	// Values <=1 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the second attempt.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts (0 = no cap).
+	MaxBackoff time.Duration
+
+	// Multiplier grows the backoff after each attempt (<=1 uses 2).
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to ±Jitter of its length (0..1).
+	Jitter float64
+
+	// Retryable decides whether an error is worth another attempt.
+	// nil retries every error.
+	Retryable func(error) bool
+
+	// Clock waits out the backoff when it is a Sleeper (nil = time.Sleep).
+	Clock Clock
+
+	random func() float64 // for testing: injectable jitter source in [0,1)
+}
+
+// RetryError is returned when a retried function gives up.
+// It records how many attempts were made and unwraps to the last error.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry: failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Retry wraps f so every call is retried according to policy.
+// The result plugs into MapE, MapToE, ParallelSeq.MapE and ParallelMapToE.
+// Failures are reported as *RetryError.
+//
+// Example:
+//
+//	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond}
+//	ParallelMapToE(From(ids).Parallel(), Retry(policy, fetch))
+func Retry[T any, R any](policy RetryPolicy, f func(T) (R, error)) func(T) (R, error) {
+	return func(v T) (R, error) {
+		backoff := policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			res, err := f(v)
+			if err == nil {
+				return res, nil
+			}
+			if attempt >= policy.MaxAttempts || (policy.Retryable != nil && !policy.Retryable(err)) {
+				var zero R
+				return zero, &RetryError{Attempts: attempt, Err: err}
+			}
+			policy.wait(backoff)
+			backoff = policy.next(backoff)
+		}
+	}
+}
+
+func (p RetryPolicy) wait(d time.Duration) {
+	if p.Jitter > 0 && d > 0 {
+		r := rand.Float64
+		if p.random != nil {
+			r = p.random
+		}
+		d += time.Duration((r()*2 - 1) * p.Jitter * float64(d))
+	}
+	if d <= 0 {
+		return
+	}
+	sleepOn(clockOr(p.Clock), d)
+}
+
+func (p RetryPolicy) next(d time.Duration) time.Duration {
+	m := p.Multiplier
+	if m <= 1 {
+		m = 2
+	}
+	d = time.Duration(float64(d) * m)
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,205 @@
+package polyfill
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+// sleepRecorder is a fake Clock that records each Sleep.
+type sleepRecorder struct {
+	*fakeclock.Clock
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (s *sleepRecorder) Sleep(d time.Duration) {
+	s.mu.Lock()
+	s.waits = append(s.waits, d)
+	s.mu.Unlock()
+	s.Clock.Sleep(d)
+}
+
+// recordSleeps returns a policy copy whose waits are recorded instead of slept.
+func recordSleeps(p RetryPolicy) (RetryPolicy, *[]time.Duration) {
+	rec := &sleepRecorder{Clock: fakeclock.New(time.Now())}
+	p.Clock = rec
+	return p, &rec.waits
+}
+
+func TestRetry_ExponentialBackoff(t *testing.T) {
+	policy, waits := recordSleeps(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	})
+
+	calls := 0
+	f := Retry(policy, func(n int) (int, error) {
+		calls++
+		if calls < 5 {
+			return 0, errors.New("flaky")
+		}
+		return n * 2, nil
+	})
+	v, err := f(21)
+	if err != nil || v != 42 {
+		t.Fatalf("unexpected: v=%v err=%v", v, err)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	if len(*waits) != len(want) {
+		t.Fatalf("expected waits %v, got %v", want, *waits)
+	}
+	for i := range want {
+		if (*waits)[i] != want[i] {
+			t.Fatalf("expected waits %v, got %v", want, *waits)
+		}
+	}
+}
+
+func TestRetry_GivesUpWithAttempts(t *testing.T) {
+	policy, _ := recordSleeps(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	boom := errors.New("boom")
+	_, err := Retry(policy, func(int) (int, error) { return 0, boom })(1)
+
+	var re *RetryError
+	if !errors.As(err, &re) || re.Attempts != 3 {
+		t.Fatalf("expected RetryError with 3 attempts, got %v", err)
+	}
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected error to unwrap to boom")
+	}
+}
+
+func TestRetry_NonRetryable(t *testing.T) {
+	permanent := errors.New("permanent")
+	policy, waits := recordSleeps(RetryPolicy{
+		MaxAttempts: 5,
+		Retryable:   func(err error) bool { return !errors.Is(err, permanent) },
+	})
+	_, err := Retry(policy, func(int) (int, error) { return 0, permanent })(1)
+
+	var re *RetryError
+	if !errors.As(err, &re) || re.Attempts != 1 {
+		t.Fatalf("expected a single attempt, got %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Fatalf("expected no waits, got %v", *waits)
+	}
+}
+
+func TestRetry_Jitter(t *testing.T) {
+	policy, waits := recordSleeps(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: 100 * time.Millisecond,
+		Jitter:         0.5,
+	})
+	policy.random = func() float64 { return 0 } // maximum negative jitter
+	Retry(policy, func(int) (int, error) { return 0, errors.New("x") })(1)
+	if len(*waits) != 1 || (*waits)[0] != 50*time.Millisecond {
+		t.Fatalf("expected a single 50ms wait, got %v", *waits)
+	}
+}
+
+func TestRetry_WithMapE(t *testing.T) {
+	policy, _ := recordSleeps(RetryPolicy{MaxAttempts: 2})
+	var mu sync.Mutex
+	seen := map[string]int{}
+	parse := func(s string) (int, error) {
+		mu.Lock()
+		seen[s]++
+		first := seen[s] == 1
+		mu.Unlock()
+		if first {
+			return 0, errors.New("transient")
+		}
+		return strconv.Atoi(s)
+	}
+
+	got, err := From([]string{"1", "2", "3"}).MapE(func(s string) (string, error) {
+		n, err := Retry(policy, parse)(s)
+		return strconv.Itoa(n * 10), err
+	}).SliceE()
+	if err != nil || len(got) != 3 || got[2] != "30" {
+		t.Fatalf("unexpected sequential result: %v %v", got, err)
+	}
+
+	clear(seen)
+	nums, err := ParallelMapToE(From([]string{"4", "5", "x"}).Parallel(), Retry(policy, parse)).SliceE()
+	var re *RetryError
+	if !errors.As(err, &re) || re.Attempts != 2 {
+		t.Fatalf("expected RetryError after 2 attempts, got %v (%v)", err, nums)
+	}
+}
+
+func TestParallelMapE(t *testing.T) {
+	got, err := From([]int{1, 2, 3}).
+		Parallel().
+		MapE(func(n int) (int, error) { return n * n, nil }).
+		SliceE()
+	if err != nil || len(got) != 3 || got[2] != 9 {
+		t.Fatalf("unexpected: %v %v", got, err)
+	}
+
+	_, err = From([]int{1, 2, 3, 4}).
+		Parallel(ParallelOptions{Workers: 1}).
+		MapE(func(n int) (int, error) {
+			if n >= 2 {
+				return 0, errors.New("bad " + strconv.Itoa(n))
+			}
+			return n, nil
+		}).
+		SliceE()
+	if err == nil || err.Error() != "bad 2" {
+		t.Fatalf("expected lowest-index error, got %v", err)
+	}
+}
+
+// gateClock blocks every Sleep until the test opens the gate for its length.
+type gateClock struct {
+	*fakeclock.Clock
+	mu    sync.Mutex
+	gates map[time.Duration]chan struct{}
+}
+
+func (g *gateClock) gate(d time.Duration) chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.gates[d] == nil {
+		g.gates[d] = make(chan struct{})
+	}
+	return g.gates[d]
+}
+
+func (g *gateClock) Sleep(d time.Duration) { <-g.gate(d) }
+
+func TestParallelMapE_LaterFailureKeepsEarlierElements(t *testing.T) {
+	clk := &gateClock{Clock: fakeclock.New(time.Now()), gates: map[time.Duration]chan struct{}{}}
+	limiter := NewRateLimiter(1, 1)
+	limiter.Clock = clk
+	limiter.Allow() // empty the bucket: the two elements wait 1s and 2s
+
+	called := make(chan int, 2)
+	done := make(chan error)
+	go func() {
+		_, err := From([]int{0, 1}).
+			Parallel(ParallelOptions{Workers: 2, RateLimit: limiter}).
+			MapE(func(n int) (int, error) {
+				called <- n
+				return 0, errors.New("bad " + strconv.Itoa(n))
+			}).
+			SliceE()
+		done <- err
+	}()
+	// let the second reservation fail first, whichever element it is
+	close(clk.gate(2 * time.Second))
+	<-called
+	close(clk.gate(time.Second))
+	if err := <-done; err == nil || err.Error() != "bad 0" {
+		t.Fatalf("expected lowest-index error, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package polyfill
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Store is a slower key/value layer behind a TieredCache, such as Redis or
+// a database table. ttl follows Set semantics where the store supports it.
+type Store[K comparable, V any] interface {
+	// Get returns found=false (and no error) for a missing key.
+	Get(ctx context.Context, key K) (val V, found bool, err error)
+	Set(ctx context.Context, key K, val V, ttl time.Duration) error
+	Delete(ctx context.Context, key K) error
+}
+
+// TTLStore may be implemented by a Store to report how long a value has
+// left (ttl semantics like Set: <0 = never expires), so TieredCache never
+// keeps a promoted copy longer than the Store does.
+type TTLStore[K comparable, V any] interface {
+	GetWithTTL(ctx context.Context, key K) (val V, ttl time.Duration, found bool, err error)
+}
+
+// WriteMode controls how TieredCache.Set treats the in-memory level.
+type WriteMode int
+
+const (
+	// WriteThrough stores the value in both levels.
+	WriteThrough WriteMode = iota
+	// WriteAround stores the value only in the Store and drops the
+	// in-memory copy, so it is promoted on the next read.
+	WriteAround
+)
+
+// TieredOptions configures a TieredCache.
+type TieredOptions struct {
+	Mode WriteMode
+
+	// L1TTL bounds how long values stay in memory, so changes made to the
+	// Store by other processes become visible (0 = the ttl given to Set,
+	// or the Store's remaining TTL for promoted reads). Values promoted
+	// from a Store that isn't a TTLStore get ttl L1TTL, so with L1TTL 0
+	// they follow L1's DefaultTTL and without one never expire from L1.
+	L1TTL time.Duration
+}
+
+// TieredCache reads through an in-memory Cache (L1) to a Store (L2),
+// promoting L2 hits into L1.
+type TieredCache[K comparable, V any] struct {
+	l1   *Cache[K, V]
+	l2   Store[K, V]
+	opts TieredOptions
+}
+
+// NewTieredCache puts l1 in front of l2.
+//
+// Example:
+//
+//	tc := NewTieredCache(NewCache[string, User](Config{MaxItems: 10_000}), redisStore,
+//		TieredOptions{L1TTL: 30 * time.Second})
+//	u, ok, err := tc.Get(ctx, "user:42")
+func NewTieredCache[K comparable, V any](l1 *Cache[K, V], l2 Store[K, V], opts TieredOptions) *TieredCache[K, V] {
+	return &TieredCache[K, V]{l1: l1, l2: l2, opts: opts}
+}
+
+// Get returns the value from L1, or from L2 (promoting it into L1).
+// Concurrent L1 misses for the same key share one L2 read.
+func (tc *TieredCache[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
+	ts, hasTTL := tc.l2.(TTLStore[K, V])
+	v, err := tc.l1.GetOrSetCtx(ctx, key, func() (V, time.Duration, error) {
+		var (
+			v     V
+			ttl   = tc.opts.L1TTL
+			found bool
+			err   error
+		)
+		if hasTTL {
+			var left time.Duration
+			v, left, found, err = ts.GetWithTTL(context.WithoutCancel(ctx), key)
+			ttl = tc.l1TTL(max(left, 0)) // never-expiring: L1's DefaultTTL
+		} else {
+			v, found, err = tc.l2.Get(context.WithoutCancel(ctx), key)
+		}
+		if err == nil && !found {
+			err = ErrNotFound
+		}
+		return v, ttl, err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return v, false, nil
+	}
+	return v, err == nil, err
+}
+
+// Set writes val to L2 and, in WriteThrough mode, to L1; in WriteAround
+// mode the L1 copy is dropped. L1 is left untouched if the L2 write fails.
+func (tc *TieredCache[K, V]) Set(ctx context.Context, key K, val V, ttl time.Duration) error {
+	if err := tc.l2.Set(ctx, key, val, ttl); err != nil {
+		return err
+	}
+	if tc.opts.Mode == WriteAround {
+		tc.l1.Delete(key)
+		return nil
+	}
+	tc.l1.Set(key, val, tc.l1TTL(ttl))
+	return nil
+}
+
+// Delete removes key from both levels. L2 goes first so a concurrent
+// promotion either reads the deleted state or is dropped by the L1 delete.
+func (tc *TieredCache[K, V]) Delete(ctx context.Context, key K) error {
+	err := tc.l2.Delete(ctx, key)
+	tc.l1.Delete(key)
+	return err
+}
+
+// L1 exposes the in-memory level, e.g. for Stats.
+func (tc *TieredCache[K, V]) L1() *Cache[K, V] {
+	return tc.l1
+}
+
+// l1TTL keeps the L1 copy from outliving L1TTL or the L2 entry.
+func (tc *TieredCache[K, V]) l1TTL(ttl time.Duration) time.Duration {
+	if tc.opts.L1TTL == 0 || (ttl > 0 && ttl < tc.opts.L1TTL) {
+		return ttl
+	}
+	return tc.opts.L1TTL
+}
+
+// MemoryStore is a Store backed by a Cache, handy for tests and as a
+// shared in-process L2.
+type MemoryStore[K comparable, V any] struct {
+	c *Cache[K, V]
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore[K comparable, V any](cfg Config) *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{c: NewCache[K, V](cfg)}
+}
+
+// Get implements Store.
+func (s *MemoryStore[K, V]) Get(_ context.Context, key K) (V, bool, error) {
+	v, ok := s.c.Get(key)
+	return v, ok, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore[K, V]) Set(_ context.Context, key K, val V, ttl time.Duration) error {
+	s.c.Set(key, val, ttl)
+	return nil
+}
+
+// GetWithTTL implements TTLStore.
+func (s *MemoryStore[K, V]) GetWithTTL(_ context.Context, key K) (V, time.Duration, bool, error) {
+	sh := s.c.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+	v, ok := sh.getLocked(key)
+	if !ok {
+		return v, 0, false, nil
+	}
+	return v, sh.items[key].remaining(s.c.clock.Now()), true, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore[K, V]) Delete(_ context.Context, key K) error {
+	s.c.Delete(key)
+	return nil
+}
@@ -0,0 +1,197 @@
+package polyfill
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lofidv/polyfill/v2/fakeclock"
+)
+
+// countingStore records L2 traffic and can be made to fail.
+type countingStore struct {
+	*MemoryStore[string, int]
+	gets, sets int
+	fail       error
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) (int, bool, error) {
+	s.gets++
+	if s.fail != nil {
+		return 0, false, s.fail
+	}
+	return s.MemoryStore.Get(ctx, key)
+}
+
+func (s *countingStore) GetWithTTL(ctx context.Context, key string) (int, time.Duration, bool, error) {
+	s.gets++
+	if s.fail != nil {
+		return 0, 0, false, s.fail
+	}
+	return s.MemoryStore.GetWithTTL(ctx, key)
+}
+
+func (s *countingStore) Set(ctx context.Context, key string, val int, ttl time.Duration) error {
+	s.sets++
+	if s.fail != nil {
+		return s.fail
+	}
+	return s.MemoryStore.Set(ctx, key, val, ttl)
+}
+
+func TestTieredCache_ReadThroughAndPromote(t *testing.T) {
+	ctx := context.Background()
+	l2 := &countingStore{MemoryStore: NewMemoryStore[string, int](Config{})}
+	l2.MemoryStore.Set(ctx, "a", 1, -1)
+	tc := NewTieredCache(NewCache[string, int](Config{}), l2, TieredOptions{})
+
+	for i := 0; i < 3; i++ {
+		if v, ok, err := tc.Get(ctx, "a"); err != nil || !ok || v != 1 {
+			t.Fatalf("unexpected: %v %v %v", v, ok, err)
+		}
+	}
+	if l2.gets != 1 || !tc.L1().Has("a") {
+		t.Fatalf("expected one L2 read and promotion, gets=%d", l2.gets)
+	}
+
+	if _, ok, err := tc.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("expected clean miss, got ok=%v err=%v", ok, err)
+	}
+	if tc.L1().Has("missing") {
+		t.Fatalf("misses must not be cached")
+	}
+
+	boom := errors.New("boom")
+	l2.fail = boom
+	if _, _, err := tc.Get(ctx, "b"); !errors.Is(err, boom) {
+		t.Fatalf("expected L2 error, got %v", err)
+	}
+}
+
+func TestTieredCache_WriteModes(t *testing.T) {
+	ctx := context.Background()
+	l2 := &countingStore{MemoryStore: NewMemoryStore[string, int](Config{})}
+	start := time.Now()
+	l1 := NewCache[string, int](Config{Clock: fakeclock.New(start)})
+	tc := NewTieredCache(l1, l2, TieredOptions{L1TTL: time.Minute})
+
+	tc.Set(ctx, "a", 1, time.Hour)
+	if v, ok := l1.Get("a"); !ok || v != 1 {
+		t.Fatalf("write-through should fill L1")
+	}
+	if exp := l1.shards[0].items["a"].expiresAt; !exp.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected L1TTL to bound the L1 copy, expires %v", exp)
+	}
+	tc.Set(ctx, "short", 1, time.Second)
+	if exp := l1.shards[0].items["short"].expiresAt; !exp.Equal(start.Add(time.Second)) {
+		t.Fatalf("L1 must not outlive the L2 entry, expires %v", exp)
+	}
+
+	tc.opts.Mode = WriteAround
+	tc.Set(ctx, "a", 2, time.Hour)
+	if l1.Has("a") {
+		t.Fatalf("write-around should drop the L1 copy")
+	}
+	if v, _, _ := tc.Get(ctx, "a"); v != 2 {
+		t.Fatalf("expected fresh value from L2, got %v", v)
+	}
+
+	l2.fail = errors.New("down")
+	if err := tc.Set(ctx, "a", 3, -1); err == nil {
+		t.Fatalf("expected L2 write error")
+	}
+	if v, _ := l1.Get("a"); v != 2 {
+		t.Fatalf("L1 must be untouched when L2 fails, got %v", v)
+	}
+
+	l2.fail = nil
+	tc.Delete(ctx, "a")
+	if _, ok, _ := tc.Get(ctx, "a"); ok {
+		t.Fatalf("expected Delete to clear both levels")
+	}
+}
+
+// gatedStore holds L2 reads after reading, until released.
+type gatedStore struct {
+	*MemoryStore[string, int]
+	entered, release chan struct{}
+}
+
+func (s *gatedStore) GetWithTTL(ctx context.Context, key string) (int, time.Duration, bool, error) {
+	v, ttl, ok, err := s.MemoryStore.GetWithTTL(ctx, key)
+	s.entered <- struct{}{}
+	<-s.release
+	return v, ttl, ok, err
+}
+
+func TestTieredCache_PromotionFollowsL2TTL(t *testing.T) {
+	ctx := context.Background()
+	clk := fakeclock.New(time.Now())
+	l2 := NewMemoryStore[string, int](Config{Clock: clk})
+	l2.Set(ctx, "a", 1, time.Minute)
+	l1 := NewCache[string, int](Config{Clock: clk})
+	tc := NewTieredCache(l1, l2, TieredOptions{})
+	tc.Get(ctx, "a")
+	if ttl, ok := l1.TTL("a"); !ok || ttl != time.Minute {
+		t.Fatalf("expected the promoted copy to expire with L2, got %v %v", ttl, ok)
+	}
+
+}
+
+func TestTieredCache_PromotionFromPlainStore(t *testing.T) {
+	ctx := context.Background()
+	l2 := NewMemoryStore[string, int](Config{})
+	l2.Set(ctx, "a", 1, time.Minute)
+	plain := struct{ Store[string, int] }{l2} // hides GetWithTTL
+
+	for name, tc := range map[string]struct {
+		l1  Config
+		ttl time.Duration
+	}{
+		"immortal":    {Config{}, 0},
+		"default-ttl": {Config{DefaultTTL: 10 * time.Second}, 10 * time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tiered := NewTieredCache(NewCache[string, int](tc.l1), plain, TieredOptions{})
+			if v, ok, err := tiered.Get(ctx, "a"); err != nil || !ok || v != 1 {
+				t.Fatalf("unexpected: %v %v %v", v, ok, err)
+			}
+			if !tiered.L1().Has("a") {
+				t.Fatalf("expected the value to be promoted")
+			}
+			if ttl, _ := tiered.L1().TTL("a"); ttl > tc.ttl || ttl < tc.ttl-time.Second {
+				t.Fatalf("expected promotion with L1's TTL %v, got %v", tc.ttl, ttl)
+			}
+			if s := tiered.L1().Stats(); s.Misses != 1 {
+				t.Fatalf("expected the read to go through L1's shared load, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestTieredCache_WriteDuringPromotion(t *testing.T) {
+	ctx := context.Background()
+	for name, write := range map[string]func(tc *TieredCache[string, int]){
+		"delete":       func(tc *TieredCache[string, int]) { tc.Delete(ctx, "a") },
+		"write-around": func(tc *TieredCache[string, int]) { tc.Set(ctx, "a", 2, -1) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			l2 := &gatedStore{NewMemoryStore[string, int](Config{}), make(chan struct{}), make(chan struct{})}
+			l2.Set(ctx, "a", 1, -1)
+			tc := NewTieredCache(NewCache[string, int](Config{}), l2, TieredOptions{Mode: WriteAround})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tc.Get(ctx, "a")
+			}()
+			<-l2.entered
+			write(tc)
+			close(l2.release)
+			<-done
+			if v, ok := tc.L1().Get("a"); ok {
+				t.Fatalf("expected the in-flight promotion to be dropped, L1 has %v", v)
+			}
+		})
+	}
+}